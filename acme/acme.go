@@ -0,0 +1,286 @@
+// Package acme 提供 ACME DNS-01 驗證，在 PChome 代管 DNS 建立和移除 _acme-challenge TXT 記錄，
+// 介面和 lego 的 challenge.Provider 及 challenge.ProviderTimeout 相容。
+package acme
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/a2n/pchome"
+)
+
+// 預設等待 TXT 記錄生效的時間上限和檢查間隔。
+const (
+	DefaultPropagationTimeout = 15 * time.Minute
+	DefaultPollingInterval = 20 * time.Second
+)
+
+// DNS-01 驗證者。
+type Provider struct {
+	// 取得已登入的服務，nil 時以目前目錄的組態登入。
+	Service func() (*pchome.Service, error)
+	// 等待 TXT 記錄在所有權威伺服器生效的時間上限和檢查間隔。
+	PropagationTimeout time.Duration
+	PollingInterval time.Duration
+	// 記錄器，nil 時使用 slog.Default。
+	Logger *slog.Logger
+
+	// 查詢 zone 的權威伺服器和向指定伺服器查詢 TXT，nil 時使用 DNS，測試時替換。
+	lookupNS func(ctx context.Context, zone string) ([]string, error)
+	lookupTXT func(ctx context.Context, server, fqdn string) ([]string, error)
+
+	mu sync.Mutex
+}
+
+// 取得使用預設時間的驗證者。
+func NewProvider() *Provider {
+	return &Provider {
+		PropagationTimeout: DefaultPropagationTimeout,
+		PollingInterval: DefaultPollingInterval,
+	}
+}
+
+// 建立驗證用的 TXT 記錄並等待所有權威伺服器生效，失敗時移除記錄。
+func (p *Provider) Present(domain, token, keyAuth string) error {
+	fqdn, value := ChallengeRecord(domain, keyAuth)
+	s, zone, name, err := p.locate(fqdn)
+	if err != nil {
+		return err
+	}
+	log := p.log().With(pchome.LogZone, zone, pchome.LogOperation, "acme.present", "name", name)
+
+	record := pchome.HostRecord{Name: name, Type: pchome.HostTXT, Content: value}
+	if err := p.add(s, zone, record); err != nil {
+		log.Error("adds challenge record failed", pchome.LogError, err)
+		return err
+	}
+	log.Info("added challenge record")
+
+	if err := p.wait(zone, fqdn, value); err != nil {
+		log.Error("challenge record not propagated", pchome.LogError, err)
+		if cleanErr := p.remove(s, zone, record); cleanErr != nil {
+			log.Error("removes challenge record failed", pchome.LogError, cleanErr)
+		}
+		return err
+	}
+	log.Info("challenge record propagated")
+
+	return nil
+}
+
+// 移除驗證用的 TXT 記錄，記錄不存在時視為成功。
+func (p *Provider) CleanUp(domain, token, keyAuth string) error {
+	fqdn, value := ChallengeRecord(domain, keyAuth)
+	s, zone, name, err := p.locate(fqdn)
+	if err != nil {
+		return err
+	}
+
+	record := pchome.HostRecord{Name: name, Type: pchome.HostTXT, Content: value}
+	if err := p.remove(s, zone, record); err != nil {
+		p.log().Error("removes challenge record failed", pchome.LogZone, zone, pchome.LogOperation, "acme.cleanup", pchome.LogError, err)
+		return err
+	}
+	p.log().Info("removed challenge record", pchome.LogZone, zone, pchome.LogOperation, "acme.cleanup", "name", name)
+
+	return nil
+}
+
+// 等待生效的時間上限和檢查間隔，lego 依此等待。
+func (p *Provider) Timeout() (timeout, interval time.Duration) {
+	return p.timeout(), p.interval()
+}
+
+// 取得驗證記錄的完整名稱和內容，內容為 keyAuth SHA-256 的 base64url 編碼。
+func ChallengeRecord(domain, keyAuth string) (fqdn, value string) {
+	domain = strings.ToLower(strings.TrimSuffix(strings.TrimPrefix(domain, "*."), "."))
+	sum := sha256.Sum256([]byte(keyAuth))
+
+	return "_acme-challenge." + domain, base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func (p *Provider) log() *slog.Logger {
+	if p.Logger == nil {
+		return slog.Default()
+	}
+
+	return p.Logger
+}
+
+func (p *Provider) timeout() time.Duration {
+	if p.PropagationTimeout <= 0 {
+		return DefaultPropagationTimeout
+	}
+
+	return p.PropagationTimeout
+}
+
+func (p *Provider) interval() time.Duration {
+	if p.PollingInterval <= 0 {
+		return DefaultPollingInterval
+	}
+
+	return p.PollingInterval
+}
+
+func (p *Provider) service() (*pchome.Service, error) {
+	if p.Service != nil {
+		return p.Service()
+	}
+
+	return pchome.NewConfigService().NewService()
+}
+
+// 找出 fqdn 所屬的 PChome zone，回傳 zone 和相對於 zone 的記錄名稱。
+func (p *Provider) locate(fqdn string) (*pchome.Service, string, string, error) {
+	s, err := p.service()
+	if err != nil {
+		return nil, "", "", err
+	}
+	zones, err := s.NewZoneService().List().Do()
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	zone := ""
+	for name := range zones {
+		if strings.HasSuffix(fqdn, "." + name) && len(name) > len(zone) {
+			zone = name
+		}
+	}
+	if len(zone) == 0 {
+		return nil, "", "", errors.New("No PChome zone contains " + fqdn + ".")
+	}
+
+	return s, zone, strings.TrimSuffix(fqdn, "." + zone), nil
+}
+
+// 添加記錄，已經存在時不重複添加。
+func (p *Provider) add(s *pchome.Service, zone string, record pchome.HostRecord) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	hs := s.NewHostService()
+	records, err := hs.List(zone)
+	if err != nil {
+		return err
+	}
+	for _, r := range records {
+		if r == record {
+			return nil
+		}
+	}
+
+	return hs.Set(zone, append(records, record))
+}
+
+// 移除記錄，不存在時不提交。
+func (p *Provider) remove(s *pchome.Service, zone string, record pchome.HostRecord) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	hs := s.NewHostService()
+	records, err := hs.List(zone)
+	if err != nil {
+		return err
+	}
+	rest := make([]pchome.HostRecord, 0, len(records))
+	for _, r := range records {
+		if r != record {
+			rest = append(rest, r)
+		}
+	}
+	if len(rest) == len(records) {
+		return nil
+	}
+
+	return hs.Set(zone, rest)
+}
+
+// 等到 zone 的每個權威伺服器都回應 value。
+func (p *Provider) wait(zone, fqdn, value string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout())
+	defer cancel()
+
+	findNS, findTXT := p.lookupNS, p.lookupTXT
+	if findNS == nil {
+		findNS = lookupNS
+	}
+	if findTXT == nil {
+		findTXT = lookupTXT
+	}
+
+	servers, err := findNS(ctx, zone)
+	if err != nil {
+		return errors.New("Looking up name servers of " + zone + " failed, " + err.Error() + ".")
+	}
+	if len(servers) == 0 {
+		return errors.New("No name server for " + zone + ".")
+	}
+
+	pending := servers
+	for {
+		rest := make([]string, 0, len(pending))
+		for _, server := range pending {
+			txts, err := findTXT(ctx, server, fqdn)
+			if err != nil || !contains(txts, value) {
+				rest = append(rest, server)
+			}
+		}
+		if len(rest) == 0 {
+			return nil
+		}
+		pending = rest
+
+		select {
+		case <-ctx.Done():
+			return errors.New("TXT record " + fqdn + " is not propagated to " + strings.Join(pending, ", ") + ".")
+		case <-time.After(p.interval()):
+		}
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// 查詢 zone 公開的權威伺服器。
+func lookupNS(ctx context.Context, zone string) ([]string, error) {
+	records, err := net.DefaultResolver.LookupNS(ctx, zone)
+	if err != nil {
+		return nil, err
+	}
+
+	servers := make([]string, 0, len(records))
+	for _, r := range records {
+		servers = append(servers, strings.TrimSuffix(r.Host, "."))
+	}
+
+	return servers, nil
+}
+
+// 直接向權威伺服器查詢 TXT，不經過快取。
+func lookupTXT(ctx context.Context, server, fqdn string) ([]string, error) {
+	r := &net.Resolver {
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, net.JoinHostPort(server, "53"))
+		},
+	}
+
+	return r.LookupTXT(ctx, fqdn)
+}
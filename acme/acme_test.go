@@ -0,0 +1,113 @@
+package acme
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/a2n/pchome"
+	"github.com/a2n/pchome/pchometest"
+)
+
+// 啟動有一個 PChome DNS 網域的模擬網站。
+func newTestSite(t *testing.T) *pchometest.Server {
+	srv := pchometest.NewServer()
+	t.Cleanup(srv.Close)
+	srv.SetHosts("example.com", []pchome.HostRecord {
+		{Name: "", Type: pchome.HostA, Content: "192.0.2.10"},
+		{Name: "www", Type: pchome.HostCNAME, Content: "example.com"},
+		{Name: "", Type: pchome.HostMX, Content: "10 mail.example.com"},
+		{Name: "_acme-challenge", Type: pchome.HostTXT, Content: "token"},
+		{Name: "blog", Type: pchome.HostForward, Content: "http://blog.example.net/"},
+	})
+
+	return srv
+}
+
+// 取得模擬網站代管記錄裡的 TXT 內容。
+func txts(t *testing.T, srv *pchometest.Server) []string {
+	records, ok := srv.Hosts("example.com")
+	if !ok {
+		t.Fatal("The zone does not use PChome DNS.")
+	}
+
+	txts := make([]string, 0)
+	for _, r := range records {
+		if r.Type == pchome.HostTXT {
+			txts = append(txts, r.Name + " " + r.Content)
+		}
+	}
+	return txts
+}
+
+func newTestProvider(srv *pchometest.Server, published func() []string) *Provider {
+	p := NewProvider()
+	p.Service = func() (*pchome.Service, error) { return srv.Service(), nil }
+	p.PropagationTimeout = 50 * time.Millisecond
+	p.PollingInterval = 5 * time.Millisecond
+	p.lookupNS = func(ctx context.Context, zone string) ([]string, error) {
+		if zone != "example.com" {
+			return nil, nil
+		}
+		return []string{"ns1.example.net", "ns2.example.net"}, nil
+	}
+	p.lookupTXT = func(ctx context.Context, server, fqdn string) ([]string, error) {
+		if fqdn != "_acme-challenge.www.example.com" {
+			return nil, nil
+		}
+		return published(), nil
+	}
+	return p
+}
+
+func TestChallengeRecord(t *testing.T) {
+	fqdn, value := ChallengeRecord("*.Example.COM.", "token.key")
+	if fqdn != "_acme-challenge.example.com" {
+		t.Errorf("FQDN %s.", fqdn)
+	}
+	if value != "BBQUgcxf5weD7GT5jGRqmNsvAZXUWBoqPngIzDdoBFs" || strings.ContainsAny(value, "+/=") {
+		t.Errorf("Value %s.", value)
+	}
+}
+
+func TestPresent(t *testing.T) {
+	srv := newTestSite(t)
+	_, value := ChallengeRecord("www.example.com", "token.key")
+	p := newTestProvider(srv, func() []string { return []string{"other", value} })
+
+	if err := p.Present("www.example.com", "token", "token.key"); err != nil {
+		t.Fatal(err.Error())
+	}
+	records := txts(t, srv)
+	if len(records) != 2 || records[1] != "_acme-challenge.www " + value {
+		t.Errorf("TXT records %v.", records)
+	}
+
+	if err := p.CleanUp("www.example.com", "token", "token.key"); err != nil {
+		t.Fatal(err.Error())
+	}
+	if records := txts(t, srv); len(records) != 1 {
+		t.Errorf("TXT records %v after cleaning up.", records)
+	}
+	posts := srv.Posts()
+	if err := p.CleanUp("www.example.com", "token", "token.key"); err != nil || srv.Posts() != posts {
+		t.Errorf("Cleaning up twice has error %v and posts %d times.", err, srv.Posts() - posts)
+	}
+
+	if err := p.Present("www.example.org", "token", "token.key"); err == nil {
+		t.Error("Presenting a domain without a PChome zone succeeds.")
+	}
+}
+
+func TestPresentNotPropagated(t *testing.T) {
+	srv := newTestSite(t)
+	p := newTestProvider(srv, func() []string { return []string{"stale"} })
+
+	if err := p.Present("www.example.com", "token", "token.key"); err == nil {
+		t.Fatal("Presenting an unpropagated record succeeds.")
+	}
+	if records := txts(t, srv); len(records) != 1 || srv.Posts() != 2 {
+		t.Errorf("TXT records %v after %d posts, want the challenge record removed.", records, srv.Posts())
+	}
+}
@@ -0,0 +1,436 @@
+package api
+
+import (
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/a2n/pchome"
+)
+
+// 每個 zone 最多的 NS 和 DNSSEC 記錄數。
+const maxRecords = 5
+
+// 新增或更新 NS 記錄的請求內容。
+type nsRequest struct {
+	Name string
+	IP string
+}
+
+// 同步的請求內容，Zones 為空時同步所有 zone。
+type syncRequest struct {
+	Zones []string
+}
+
+// 同步的回應，Errors 為同步失敗的 zone。
+type syncResponse struct {
+	Zones map[string]pchome.Zone
+	Errors map[string]string `json:",omitempty"`
+}
+
+// 檢查 NS 記錄，回傳小寫的主機名稱。IP 可以為空，表示不需要 glue。
+func validNS(name, ip string) (string, error) {
+	if !pchome.ValidHost(name) {
+		return "", badRequest("Bad host name " + name + ".")
+	}
+	if !pchome.ValidGlue(ip) {
+		return "", badRequest("Bad ip " + ip + ".")
+	}
+
+	return strings.ToLower(strings.TrimSuffix(name, ".")), nil
+}
+
+// 檢查 DS 記錄的 digest，長度須為 SHA-1、SHA-256 或 SHA-384。
+func validDNSSEC(record pchome.DNSSEC) error {
+	if _, err := hex.DecodeString(record.Digest); err != nil {
+		return badRequest("Digest " + record.Digest + " is not hex.")
+	}
+	switch len(record.Digest) {
+	case 40, 64, 96:
+	default:
+		return badRequest("Digest " + record.Digest + " has a bad length.")
+	}
+
+	return nil
+}
+
+// 比較兩筆 DS 記錄，digest 不分大小寫。
+func sameDNSSEC(a, b pchome.DNSSEC) bool {
+	return a.KeyTag == b.KeyTag && a.Algorithm == b.Algorithm && strings.EqualFold(a.Digest, b.Digest)
+}
+
+// 取得本地組態裡的 zone。
+func (s *Server) zone(name string) (pchome.Zone, error) {
+	config, err := s.cs.Read()
+	if err != nil {
+		return pchome.Zone{}, err
+	}
+
+	zone, ok := config.Zones[name]
+	if !ok {
+		return pchome.Zone{}, notFound("No such zone " + name + ".")
+	}
+
+	return zone, nil
+}
+
+// 回傳異動後的 zone。
+func (s *Server) writeZone(w http.ResponseWriter, status int, name string) error {
+	zone, err := s.zone(name)
+	if err != nil {
+		return err
+	}
+
+	writeJSON(w, status, zone)
+	return nil
+}
+
+func (s *Server) listZones(w http.ResponseWriter, r *http.Request) error {
+	config, err := s.cs.Read()
+	if err != nil {
+		return err
+	}
+	if config.Zones == nil {
+		config.Zones = make(map[string]pchome.Zone)
+	}
+
+	writeJSON(w, http.StatusOK, config.Zones)
+	return nil
+}
+
+func (s *Server) getZone(w http.ResponseWriter, r *http.Request) error {
+	return s.writeZone(w, http.StatusOK, r.PathValue("zone"))
+}
+
+func (s *Server) sync(w http.ResponseWriter, r *http.Request) error {
+	var req syncRequest
+	if r.ContentLength != 0 {
+		if err := decode(r, &req); err != nil {
+			return err
+		}
+	}
+
+	err := s.lock(func() error {
+		if len(req.Zones) == 0 {
+			return s.cs.Update()
+		}
+
+		svc, err := s.service()
+		if err != nil {
+			return err
+		}
+		_, err = s.cs.UpdateZonesByNames(svc, req.Zones)
+		return err
+	})
+	resp := syncResponse{}
+	if syncErr, ok := err.(*pchome.SyncError); ok {
+		resp.Errors = make(map[string]string)
+		for name, err := range syncErr.Errors {
+			resp.Errors[name] = err.Error()
+		}
+	} else if err != nil {
+		return err
+	}
+
+	config, err := s.cs.Read()
+	if err != nil {
+		return err
+	}
+	resp.Zones = config.Zones
+	writeJSON(w, http.StatusOK, resp)
+	return nil
+}
+
+func (s *Server) listNS(w http.ResponseWriter, r *http.Request) error {
+	name := r.PathValue("zone")
+	if _, err := s.zone(name); err != nil {
+		return err
+	}
+	svc, err := s.service()
+	if err != nil {
+		return err
+	}
+
+	record, err := svc.NewNSService().List(name)
+	if err != nil {
+		return err
+	}
+
+	writeJSON(w, http.StatusOK, record)
+	return nil
+}
+
+func (s *Server) addNS(w http.ResponseWriter, r *http.Request) error {
+	name := r.PathValue("zone")
+	var req nsRequest
+	if err := decode(r, &req); err != nil {
+		return err
+	}
+	host, err := validNS(req.Name, req.IP)
+	if err != nil {
+		return err
+	}
+
+	err = s.lock(func() error {
+		zone, err := s.zone(name)
+		if err != nil {
+			return err
+		}
+		if _, ok := zone.NS[host]; ok {
+			return conflict("Duplicated host name " + host + ".")
+		}
+		if len(zone.NS) >= maxRecords {
+			return conflict("The zone has reached the max NS record count 5.")
+		}
+
+		svc, err := s.service()
+		if err != nil {
+			return err
+		}
+		return svc.NewNSService().Add(name, host, req.IP)
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.writeZone(w, http.StatusCreated, name)
+}
+
+func (s *Server) setNS(w http.ResponseWriter, r *http.Request) error {
+	name := r.PathValue("zone")
+	var req pchome.NS
+	if err := decode(r, &req); err != nil {
+		return err
+	}
+	if len(req) > maxRecords {
+		return badRequest("A zone can have at most 5 NS records.")
+	}
+	record := make(pchome.NS)
+	for host, ip := range req {
+		host, err := validNS(host, ip)
+		if err != nil {
+			return err
+		}
+		record[host] = ip
+	}
+
+	err := s.lock(func() error {
+		if _, err := s.zone(name); err != nil {
+			return err
+		}
+
+		svc, err := s.service()
+		if err != nil {
+			return err
+		}
+		return svc.NewNSService().Set(name, record)
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.writeZone(w, http.StatusOK, name)
+}
+
+func (s *Server) updateNS(w http.ResponseWriter, r *http.Request) error {
+	name := r.PathValue("zone")
+	var req nsRequest
+	if err := decode(r, &req); err != nil {
+		return err
+	}
+	if len(req.Name) > 0 && req.Name != r.PathValue("name") {
+		return badRequest("The host name in the body differs from the path.")
+	}
+	host, err := validNS(r.PathValue("name"), req.IP)
+	if err != nil {
+		return err
+	}
+
+	err = s.lock(func() error {
+		zone, err := s.zone(name)
+		if err != nil {
+			return err
+		}
+		if _, ok := zone.NS[host]; !ok {
+			return notFound("No such host name " + host + ".")
+		}
+
+		svc, err := s.service()
+		if err != nil {
+			return err
+		}
+		return svc.NewNSService().Update(name, host, req.IP)
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.writeZone(w, http.StatusOK, name)
+}
+
+func (s *Server) deleteNS(w http.ResponseWriter, r *http.Request) error {
+	name := r.PathValue("zone")
+	host, err := validNS(r.PathValue("name"), "")
+	if err != nil {
+		return err
+	}
+
+	err = s.lock(func() error {
+		zone, err := s.zone(name)
+		if err != nil {
+			return err
+		}
+		ip, ok := zone.NS[host]
+		if !ok {
+			return notFound("No such host name " + host + ".")
+		}
+
+		svc, err := s.service()
+		if err != nil {
+			return err
+		}
+		return svc.NewNSService().Delete(name, host, ip)
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.writeZone(w, http.StatusOK, name)
+}
+
+func (s *Server) listDNSSEC(w http.ResponseWriter, r *http.Request) error {
+	name := r.PathValue("zone")
+	if _, err := s.zone(name); err != nil {
+		return err
+	}
+	svc, err := s.service()
+	if err != nil {
+		return err
+	}
+
+	records, err := svc.NewDNSSECService().List(name)
+	if err != nil {
+		return err
+	}
+
+	writeJSON(w, http.StatusOK, records)
+	return nil
+}
+
+func (s *Server) addDNSSEC(w http.ResponseWriter, r *http.Request) error {
+	name := r.PathValue("zone")
+	var record pchome.DNSSEC
+	if err := decode(r, &record); err != nil {
+		return err
+	}
+	if err := validDNSSEC(record); err != nil {
+		return err
+	}
+	record.Digest = strings.ToUpper(record.Digest)
+
+	err := s.lock(func() error {
+		zone, err := s.zone(name)
+		if err != nil {
+			return err
+		}
+		for _, r := range zone.DNSSEC {
+			if sameDNSSEC(r, record) {
+				return conflict("Duplicated DNSSEC record.")
+			}
+		}
+		if len(zone.DNSSEC) >= maxRecords {
+			return conflict("The zone has reached the max DNSSEC record count 5.")
+		}
+
+		svc, err := s.service()
+		if err != nil {
+			return err
+		}
+		return svc.NewDNSSECService().Add(name, record.KeyTag, record.Algorithm, record.Digest)
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.writeZone(w, http.StatusCreated, name)
+}
+
+func (s *Server) setDNSSEC(w http.ResponseWriter, r *http.Request) error {
+	name := r.PathValue("zone")
+	var records []pchome.DNSSEC
+	if err := decode(r, &records); err != nil {
+		return err
+	}
+	if len(records) > maxRecords {
+		return badRequest("A zone can have at most 5 DNSSEC records.")
+	}
+	for _, record := range records {
+		if err := validDNSSEC(record); err != nil {
+			return err
+		}
+	}
+
+	err := s.lock(func() error {
+		if _, err := s.zone(name); err != nil {
+			return err
+		}
+
+		svc, err := s.service()
+		if err != nil {
+			return err
+		}
+		return svc.NewDNSSECService().Set(name, records)
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.writeZone(w, http.StatusOK, name)
+}
+
+func (s *Server) deleteDNSSEC(w http.ResponseWriter, r *http.Request) error {
+	name := r.PathValue("zone")
+	keyTag, err := strconv.ParseUint(r.PathValue("keyTag"), 10, 16)
+	if err != nil {
+		return badRequest("Bad key tag " + r.PathValue("keyTag") + ".")
+	}
+	algorithm, err := strconv.ParseUint(r.PathValue("algorithm"), 10, 8)
+	if err != nil {
+		return badRequest("Bad algorithm " + r.PathValue("algorithm") + ".")
+	}
+	record := pchome.DNSSEC {
+		KeyTag: uint16(keyTag),
+		Algorithm: uint8(algorithm),
+		Digest: strings.ToUpper(r.PathValue("digest")),
+	}
+
+	err = s.lock(func() error {
+		zone, err := s.zone(name)
+		if err != nil {
+			return err
+		}
+		found := false
+		for _, r := range zone.DNSSEC {
+			if sameDNSSEC(r, record) {
+				// 組態保留網頁上的大小寫。
+				record.Digest = r.Digest
+				found = true
+			}
+		}
+		if !found {
+			return notFound("No such DNSSEC record.")
+		}
+
+		svc, err := s.service()
+		if err != nil {
+			return err
+		}
+		return svc.NewDNSSECService().Delete(name, record.KeyTag, record.Algorithm, record.Digest)
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.writeZone(w, http.StatusOK, name)
+}
@@ -0,0 +1,214 @@
+// Package api 以需要認證的 JSON HTTP API 提供 zone、NS 和 DNSSEC 的操作。
+package api
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"crypto/subtle"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/a2n/pchome"
+)
+
+// 請求內容的大小上限。
+const maxBody = 1 << 20
+
+//go:embed openapi.json
+var openAPI []byte
+
+// API 伺服器。
+type Server struct {
+	cs *pchome.ConfigService
+	tokens [][sha256.Size]byte
+	mux *http.ServeMux
+
+	// 取得已登入的服務，nil 時使用 ConfigService.NewService。
+	Service func() (*pchome.Service, error)
+	// 持有組態鎖執行會寫入組態的操作，nil 時直接執行。
+	Lock func(fn func() error) error
+	// 記錄器，nil 時使用 slog.Default。
+	Logger *slog.Logger
+}
+
+// 取得 API 伺服器，請求需要帶 Authorization: Bearer 和 tokens 之一。
+func NewServer(cs *pchome.ConfigService, tokens []string) *Server {
+	s := &Server {
+		cs: cs,
+		mux: http.NewServeMux(),
+	}
+	for _, token := range tokens {
+		if len(token) > 0 {
+			s.tokens = append(s.tokens, sha256.Sum256([]byte(token)))
+		}
+	}
+
+	s.mux.HandleFunc("GET /v1/openapi.json", s.openAPI)
+	s.handle("GET /v1/zones", s.listZones)
+	s.handle("GET /v1/zones/{zone}", s.getZone)
+	s.handle("POST /v1/sync", s.sync)
+	s.handle("GET /v1/zones/{zone}/ns", s.listNS)
+	s.handle("POST /v1/zones/{zone}/ns", s.addNS)
+	s.handle("PUT /v1/zones/{zone}/ns", s.setNS)
+	s.handle("PUT /v1/zones/{zone}/ns/{name}", s.updateNS)
+	s.handle("DELETE /v1/zones/{zone}/ns/{name}", s.deleteNS)
+	s.handle("GET /v1/zones/{zone}/dnssec", s.listDNSSEC)
+	s.handle("POST /v1/zones/{zone}/dnssec", s.addDNSSEC)
+	s.handle("PUT /v1/zones/{zone}/dnssec", s.setDNSSEC)
+	s.handle("DELETE /v1/zones/{zone}/dnssec/{keyTag}/{algorithm}/{digest}", s.deleteDNSSEC)
+
+	return s
+}
+
+// 讀取 token 檔案，一行一個，# 開頭為註解。
+func ReadTokens(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.New("Opening token file failed, " + err.Error() + ".")
+	}
+	defer f.Close()
+
+	tokens := make([]string, 0)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		tokens = append(tokens, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.New("Scanning token file failed, " + err.Error() + ".")
+	}
+
+	return tokens, nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// 註冊需要認證的 handler。
+func (s *Server) handle(pattern string, fn func(w http.ResponseWriter, r *http.Request) error) {
+	s.mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		if !s.authorized(r) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="pchome"`)
+			writeError(w, &Error{Status: http.StatusUnauthorized, Msg: "Missing or bad API token."})
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxBody)
+		if err := fn(w, r); err != nil {
+			var apiErr *Error
+			if !errors.As(err, &apiErr) {
+				apiErr = &Error{Status: http.StatusBadGateway, Msg: err.Error()}
+			}
+			s.log().Warn("api request failed", "method", r.Method, "path", r.URL.Path, pchome.LogStatus, apiErr.Status, pchome.LogError, apiErr.Msg)
+			writeError(w, apiErr)
+		}
+	})
+}
+
+// 請求是否帶有效的 token，以固定時間比較避免洩漏。
+func (s *Server) authorized(r *http.Request) bool {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return false
+	}
+	sum := sha256.Sum256([]byte(strings.TrimPrefix(auth, "Bearer ")))
+
+	ok := 0
+	for _, token := range s.tokens {
+		ok |= subtle.ConstantTimeCompare(sum[:], token[:])
+	}
+
+	return ok == 1
+}
+
+func (s *Server) log() *slog.Logger {
+	if s.Logger == nil {
+		return slog.Default()
+	}
+
+	return s.Logger
+}
+
+// 取得已登入的服務。
+func (s *Server) service() (*pchome.Service, error) {
+	if s.Service != nil {
+		return s.Service()
+	}
+
+	return s.cs.NewService()
+}
+
+// 持有組態鎖執行 fn。
+func (s *Server) lock(fn func() error) error {
+	if s.Lock == nil {
+		return fn()
+	}
+
+	return s.Lock(fn)
+}
+
+// API 錯誤，帶 HTTP 狀態碼。
+type Error struct {
+	Status int `json:"-"`
+	Msg string `json:"Error"`
+}
+
+func (e *Error) Error() string {
+	return e.Msg
+}
+
+// 請求內容或參數不正確。
+func badRequest(msg string) error {
+	return &Error{Status: http.StatusBadRequest, Msg: msg}
+}
+
+// 找不到 zone 或記錄。
+func notFound(msg string) error {
+	return &Error{Status: http.StatusNotFound, Msg: msg}
+}
+
+// 和現有記錄衝突。
+func conflict(msg string) error {
+	return &Error{Status: http.StatusConflict, Msg: msg}
+}
+
+func writeError(w http.ResponseWriter, e *Error) {
+	writeJSON(w, e.Status, e)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", " ")
+	encoder.Encode(v)
+}
+
+// 解析 JSON 請求內容，不接受未知的欄位。
+func decode(r *http.Request, v interface{}) error {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return badRequest("Bad JSON body, " + err.Error() + ".")
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return badRequest("Bad JSON body, trailing data.")
+	}
+
+	return nil
+}
+
+func (s *Server) openAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPI)
+}
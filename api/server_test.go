@@ -0,0 +1,169 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/a2n/pchome"
+	"github.com/a2n/pchome/pchometest"
+)
+
+// 在暫存目錄建立組態，回傳連到模擬網站的 API 伺服器。
+func newTestServer(t *testing.T) (*Server, *pchometest.Server) {
+	srv := pchometest.NewServer()
+	t.Cleanup(srv.Close)
+	zone := pchome.Zone{NS: pchome.NS{"ns1.example.com": "192.0.2.1"}}
+	srv.SetZone("example.com", zone)
+
+	dir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err.Error())
+	}
+	t.Cleanup(func() { os.Chdir(dir) })
+
+	cs := pchome.NewConfigService()
+	config := pchome.Config {
+		Zones: map[string]pchome.Zone{"example.com": zone},
+	}
+	if err := cs.Save(&config); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	s := NewServer(cs, []string{"secret", ""})
+	s.Service = func() (*pchome.Service, error) { return srv.Service(), nil }
+
+	return s, srv
+}
+
+func do(s *Server, method, path, token, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if len(token) > 0 {
+		req.Header.Set("Authorization", "Bearer " + token)
+	}
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	return w
+}
+
+func TestServerAuth(t *testing.T) {
+	s, _ := newTestServer(t)
+
+	for _, token := range []string{"", "wrong"} {
+		w := do(s, "GET", "/v1/zones", token, "")
+		if w.Code != http.StatusUnauthorized || len(w.Header().Get("WWW-Authenticate")) == 0 {
+			t.Errorf("Token %q responds %d.", token, w.Code)
+		}
+	}
+
+	if w := do(s, "GET", "/v1/openapi.json", "", ""); w.Code != http.StatusOK || !json.Valid(w.Body.Bytes()) {
+		t.Errorf("OpenAPI document responds %d.", w.Code)
+	}
+
+	w := do(s, "GET", "/v1/zones", "secret", "")
+	var zones map[string]pchome.Zone
+	if err := json.Unmarshal(w.Body.Bytes(), &zones); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("Listing zones responds %d, %s.", w.Code, w.Body.String())
+	}
+	if _, ok := zones["example.com"]; !ok {
+		t.Errorf("Zones %v miss example.com.", zones)
+	}
+}
+
+func TestServerValidation(t *testing.T) {
+	s, _ := newTestServer(t)
+
+	tests := []struct {
+		method, path, body string
+		status int
+	}{
+		{"GET", "/v1/zones/example.org", "", http.StatusNotFound},
+		{"POST", "/v1/zones/example.com/ns", `{"Name": "bad_host", "IP": ""}`, http.StatusBadRequest},
+		{"POST", "/v1/zones/example.com/ns", `{"Name": "ns2.example.com", "IP": "300.0.0.1"}`, http.StatusBadRequest},
+		{"POST", "/v1/zones/example.com/ns", `{"Name": "ns2.example.com", "Port": 53}`, http.StatusBadRequest},
+		{"POST", "/v1/zones/example.com/ns", `{"Name": "ns1.example.com", "IP": ""}`, http.StatusConflict},
+		{"POST", "/v1/zones/example.org/ns", `{"Name": "ns1.example.org", "IP": ""}`, http.StatusNotFound},
+		{"PUT", "/v1/zones/example.com/ns/ns9.example.com", `{"IP": "192.0.2.9"}`, http.StatusNotFound},
+		{"POST", "/v1/zones/example.com/dnssec", `{"KeyTag": 1, "Algorithm": 13, "Digest": "zz"}`, http.StatusBadRequest},
+		{"POST", "/v1/zones/example.com/dnssec", `{"KeyTag": 70000, "Algorithm": 13, "Digest": ""}`, http.StatusBadRequest},
+		{"DELETE", "/v1/zones/example.com/dnssec/x/13/ab", "", http.StatusBadRequest},
+		{"DELETE", "/v1/zones/example.com/dnssec/1/13/" + strings.Repeat("ab", 32), "", http.StatusNotFound},
+	}
+	for _, test := range tests {
+		w := do(s, test.method, test.path, "secret", test.body)
+		if w.Code != test.status {
+			t.Errorf("%s %s responds %d, expects %d, %s.", test.method, test.path, w.Code, test.status, w.Body.String())
+		}
+	}
+}
+
+func TestServerNS(t *testing.T) {
+	s, srv := newTestServer(t)
+	locked := 0
+	s.Lock = func(fn func() error) error {
+		locked++
+		return fn()
+	}
+
+	w := do(s, "GET", "/v1/zones/example.com/ns", "secret", "")
+	var record pchome.NS
+	if err := json.Unmarshal(w.Body.Bytes(), &record); err != nil || w.Code != http.StatusOK || len(record) == 0 {
+		t.Fatalf("Listing NS responds %d, %s.", w.Code, w.Body.String())
+	}
+
+	w = do(s, "POST", "/v1/zones/example.com/ns", "secret", `{"Name": "NS2.example.com.", "IP": "192.0.2.2"}`)
+	var zone pchome.Zone
+	if err := json.Unmarshal(w.Body.Bytes(), &zone); err != nil || w.Code != http.StatusCreated {
+		t.Fatalf("Adding NS responds %d, %s.", w.Code, w.Body.String())
+	}
+	if zone.NS["ns2.example.com"] != "192.0.2.2" {
+		t.Errorf("Zone %v misses the added record.", zone.NS)
+	}
+
+	w = do(s, "DELETE", "/v1/zones/example.com/ns/ns1.example.com", "secret", "")
+	zone = pchome.Zone{}
+	if err := json.Unmarshal(w.Body.Bytes(), &zone); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("Deleting NS responds %d, %s.", w.Code, w.Body.String())
+	}
+	if _, ok := zone.NS["ns1.example.com"]; ok {
+		t.Errorf("Zone %v still has the deleted record.", zone.NS)
+	}
+	if locked != 2 {
+		t.Errorf("Mutations take the lock %d times.", locked)
+	}
+	if site, _ := srv.Zone("example.com"); len(site.NS) != 1 || site.NS["ns2.example.com"] != "192.0.2.2" {
+		t.Errorf("Site has NS %v.", site.NS)
+	}
+}
+
+func TestServerDNSSECDigestCase(t *testing.T) {
+	s, srv := newTestServer(t)
+	digest := strings.Repeat("ab", 32)
+	zone := pchome.Zone {
+		NS: pchome.NS{"ns1.example.com": "192.0.2.1"},
+		DNSSEC: []pchome.DNSSEC{{KeyTag: 2371, Algorithm: 13, Digest: digest}},
+	}
+	srv.SetZone("example.com", zone)
+	if err := s.cs.SaveZone("example.com", zone); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	w := do(s, "POST", "/v1/zones/example.com/dnssec", "secret", `{"KeyTag": 2371, "Algorithm": 13, "Digest": "` + strings.ToUpper(digest) + `"}`)
+	if w.Code != http.StatusConflict {
+		t.Errorf("Adding an uppercase duplicate responds %d, %s.", w.Code, w.Body.String())
+	}
+
+	w = do(s, "DELETE", "/v1/zones/example.com/dnssec/2371/13/" + strings.ToUpper(digest), "secret", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Deleting with an uppercase digest responds %d, %s.", w.Code, w.Body.String())
+	}
+	if site, _ := srv.Zone("example.com"); len(site.DNSSEC) != 0 {
+		t.Errorf("Site still has DS %v.", site.DNSSEC)
+	}
+}
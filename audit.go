@@ -0,0 +1,172 @@
+package pchome
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// 預設的稽核日誌檔案位置，每行一筆 JSON。
+const DefaultAuditPath = ".pchome-audit.jsonl"
+
+// 異動結果。
+const (
+	AuditSuccess = "success"
+	AuditFailure = "failure"
+)
+
+// 稽核紀錄，記錄一次 NS 或 DNSSEC 異動。
+type AuditEntry struct {
+	Time time.Time
+	// 執行異動的作業系統使用者。
+	User string
+	// 組態檔案的絕對路徑。
+	Profile string
+	Zone string
+	// 異動名稱，例如 ns.add 或 dnssec.set。
+	Operation string
+	// 異動前的快照編號。
+	Snapshot string `json:",omitempty"`
+	Before Zone
+	After Zone
+	// AuditSuccess 或 AuditFailure。
+	Outcome string
+	// PChome 的 HTTP 狀態碼，沒有送出請求時為 0。
+	Status int `json:",omitempty"`
+	Error string `json:",omitempty"`
+}
+
+// 保護稽核日誌，避免並行寫入交錯。
+var auditMu sync.Mutex
+
+// 取得目前的作業系統使用者名稱。
+func osUser() string {
+	if u, err := user.Current(); err == nil && len(u.Username) > 0 {
+		return u.Username
+	}
+	if name := os.Getenv("USER"); len(name) > 0 {
+		return name
+	}
+
+	return os.Getenv("USERNAME")
+}
+
+// 取得組態檔案的絕對路徑。
+func profile() string {
+	path, err := filepath.Abs(DefaultConfigPath)
+	if err != nil {
+		return DefaultConfigPath
+	}
+
+	return path
+}
+
+// 附加一筆稽核紀錄。
+func (cs *ConfigService) appendAudit(entry AuditEntry) error {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	if len(entry.User) == 0 {
+		entry.User = osUser()
+	}
+	if len(entry.Profile) == 0 {
+		entry.Profile = profile()
+	}
+
+	b, err := json.Marshal(entry)
+	if err != nil {
+		cs.log().Error("marshal json failed", LogError, err)
+		return errors.New("Marshal json failed.")
+	}
+
+	auditMu.Lock()
+	defer auditMu.Unlock()
+
+	f, err := os.OpenFile(DefaultAuditPath, os.O_WRONLY | os.O_APPEND | os.O_CREATE, 0600)
+	if err != nil {
+		cs.log().Error("opens audit file failed", LogError, err)
+		return errors.New("Opening audit file failed.")
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		cs.log().Error("write audit file failed", LogError, err)
+		return errors.New("Writing audit file failed.")
+	}
+
+	return nil
+}
+
+// 讀取稽核日誌，zone 不為空時只回傳該 zone 的紀錄，依時間先後排列。
+func (cs *ConfigService) History(zone string) ([]AuditEntry, error) {
+	f, err := os.Open(DefaultAuditPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []AuditEntry{}, nil
+		}
+		cs.log().Error("opens audit file failed", LogError, err)
+		return nil, errors.New("Opening audit file failed.")
+	}
+	defer f.Close()
+
+	entries := make([]AuditEntry, 0)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64 * 1024), 1024 * 1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var entry AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			cs.log().Warn("skips bad audit line", "line", line, LogError, err)
+			continue
+		}
+		if len(zone) > 0 && entry.Zone != zone {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		cs.log().Error("scan audit file failed", LogError, err)
+		return nil, errors.New("Scanning audit file failed.")
+	}
+
+	return entries, nil
+}
+
+// 取得本地組態裡 zone 目前的內容，讀取失敗時回傳空的 zone。
+func (cs *ConfigService) currentZone(name string) Zone {
+	config, err := cs.Read()
+	if err != nil {
+		return Zone{}
+	}
+
+	return config.Zones[name]
+}
+
+// 記錄一次異動，寫入失敗只記錄錯誤，不影響已經送出的異動。
+func (cs *ConfigService) audit(op, zone, snapshot string, before, after Zone, status int, err error) {
+	entry := AuditEntry {
+		Zone: zone,
+		Operation: op,
+		Snapshot: snapshot,
+		Before: before,
+		After: after,
+		Outcome: AuditSuccess,
+		Status: status,
+	}
+	if err != nil {
+		entry.Outcome = AuditFailure
+		entry.Error = err.Error()
+	}
+
+	if err := cs.appendAudit(entry); err != nil {
+		cs.log().Error("audit failed", LogOperation, op, LogZone, zone, LogError, err)
+	}
+}
@@ -0,0 +1,42 @@
+package pchome
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestAudit(t *testing.T) {
+	dir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Chdir(dir)
+
+	cs := NewConfigService()
+	before := Zone{NS: NS{"ns0.example.com": "10.0.0.0"}}
+	after := Zone{NS: NS{"ns0.example.com": "10.0.0.1"}, DNSSEC: []DNSSEC{{KeyTag: 1234, Algorithm: 13, Digest: "ab"}}}
+	cs.audit("ns.update", "example.com", "", before, after, 200, nil)
+	cs.audit("ns.add", "example.net", "", Zone{}, after, 500, errors.New("Unexpected http status 500."))
+
+	entries, err := cs.History("example.com")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(entries) != 1 || entries[0].Outcome != AuditSuccess || entries[0].Status != 200 || len(entries[0].Profile) == 0 {
+		t.Fatalf("History has entries %+v.", entries)
+	}
+
+	changes := DiffZone(entries[0].Before, entries[0].After)
+	if len(changes) != 2 || changes[0].String() != "~ns ns0.example.com 10.0.0.0 -> 10.0.0.1" || changes[1].String() != "+dnssec 1234 13 AB" {
+		t.Errorf("Diff has changes %v.", changes)
+	}
+
+	entries, err = cs.History("")
+	if err != nil || len(entries) != 2 || entries[1].Outcome != AuditFailure {
+		t.Errorf("History has entries %+v, %v.", entries, err)
+	}
+}
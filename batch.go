@@ -0,0 +1,304 @@
+package pchome
+
+import (
+	"time"
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+	"errors"
+)
+
+// 批次操作，套用到每個選取的 zone。
+type BatchOperation struct {
+	// 取代 zone 的 NS 記錄，nil 表示不修改。
+	NS NS `json:",omitempty"`
+	// 取代 zone 的 DNSSEC 記錄，nil 表示不修改，空 slice 表示全部移除。
+	DNSSEC []DNSSEC
+	// 添加的 DNSSEC 記錄，已存在時略過。
+	AddDNSSEC []DNSSEC `json:",omitempty"`
+	// 移除的 DNSSEC 記錄，不存在時略過。
+	DeleteDNSSEC []DNSSEC `json:",omitempty"`
+}
+
+// 單一 zone 的批次結果。
+type BatchResult struct {
+	Zone string
+	// 從檢查點略過，先前已經成功。
+	Skipped bool `json:",omitempty"`
+	Error string `json:",omitempty"`
+	Time int64
+}
+
+// 批次報告。
+type BatchReport struct {
+	Operation BatchOperation
+	Results []BatchResult
+	Succeeded int
+	Failed int
+	Skipped int
+}
+
+// 批次檢查點，記錄已經成功的 zone。
+type batchCheckpoint struct {
+	Operation BatchOperation
+	Done map[string]int64
+}
+
+// 批次服務結構。
+type BatchService struct {
+	Service *Service
+}
+
+// 取得批次服務。
+func (s *Service) NewBatchService() *BatchService {
+	return &BatchService {
+		Service: s,
+	}
+}
+
+// 取得批次套用調用結構。
+func (bs *BatchService) Apply(zones []string, op BatchOperation) *BatchApplyCall {
+	return &BatchApplyCall {
+		Service: bs.Service,
+		zones: zones,
+		op: op,
+		concurrency: DefaultSyncOptions.Concurrency,
+	}
+}
+
+// 批次套用調用結構。
+type BatchApplyCall struct {
+	Service *Service
+	zones []string
+	op BatchOperation
+	concurrency int
+	checkpoint string
+	progress func(BatchResult)
+}
+
+// 設定同時處理的 zone 數量。
+func (bac *BatchApplyCall) Concurrency(n int) *BatchApplyCall {
+	if n > 0 {
+		bac.concurrency = n
+	}
+	return bac
+}
+
+// 設定檢查點檔案，中斷後再執行相同操作會略過已經成功的 zone。
+func (bac *BatchApplyCall) Checkpoint(path string) *BatchApplyCall {
+	bac.checkpoint = path
+	return bac
+}
+
+// 設定進度回報，每完成一個 zone 呼叫一次。
+func (bac *BatchApplyCall) Progress(fn func(BatchResult)) *BatchApplyCall {
+	bac.progress = fn
+	return bac
+}
+
+// 執行批次套用調用，回傳每個 zone 的結果。
+// 全部成功後會移除檢查點檔案。
+func (bac *BatchApplyCall) Do() (*BatchReport, error) {
+	cp, err := bac.readCheckpoint()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &BatchReport {
+		Operation: bac.op,
+		Results: make([]BatchResult, 0, len(bac.zones)),
+	}
+	jobs := make(chan string)
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < bac.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for zone := range jobs {
+				result := BatchResult {
+					Zone: zone,
+				}
+
+				mu.Lock()
+				_, done := cp.Done[zone]
+				mu.Unlock()
+				if done {
+					result.Skipped = true
+				} else if err := bac.apply(bac.Service, zone); err != nil {
+					result.Error = err.Error()
+				}
+				result.Time = time.Now().Unix()
+
+				mu.Lock()
+				switch {
+				case result.Skipped:
+					report.Skipped++
+				case len(result.Error) > 0:
+					report.Failed++
+				default:
+					report.Succeeded++
+					cp.Done[zone] = result.Time
+					bac.saveCheckpoint(cp)
+				}
+				report.Results = append(report.Results, result)
+				mu.Unlock()
+
+				if bac.progress != nil {
+					bac.progress(result)
+				}
+			}
+		}()
+	}
+
+	for _, zone := range bac.zones {
+		jobs <- zone
+	}
+	close(jobs)
+	wg.Wait()
+	sort.Slice(report.Results, func(i, j int) bool {
+		return report.Results[i].Zone < report.Results[j].Zone
+	})
+
+	if report.Failed == 0 && len(bac.checkpoint) > 0 {
+		if err := os.Remove(bac.checkpoint); err != nil && !os.IsNotExist(err) {
+			bac.Service.log().Error("remove checkpoint file failed", LogError, err)
+		}
+	}
+
+	return report, nil
+}
+
+// 對單一 zone 套用批次操作。
+func (bac *BatchApplyCall) apply(s *Service, zone string) error {
+	if bac.op.NS != nil {
+		if err := s.NewNSService().Set(zone, bac.op.NS); err != nil {
+			return err
+		}
+	}
+
+	if bac.op.DNSSEC == nil && len(bac.op.AddDNSSEC) == 0 && len(bac.op.DeleteDNSSEC) == 0 {
+		return nil
+	}
+
+	records := bac.op.DNSSEC
+	if records == nil {
+		config, err := NewConfigService().Read()
+		if err != nil {
+			return err
+		}
+		records = config.Zones[zone].DNSSEC
+	}
+
+	changed := make([]DNSSEC, 0, len(records))
+	for _, record := range records {
+		if !containsDNSSEC(bac.op.DeleteDNSSEC, record) {
+			changed = append(changed, record)
+		}
+	}
+	for _, record := range bac.op.AddDNSSEC {
+		if !containsDNSSEC(changed, record) {
+			changed = append(changed, record)
+		}
+	}
+
+	return s.NewDNSSECService().Set(zone, changed)
+}
+
+// 讀取檢查點，操作不同時不沿用舊的檢查點。
+func (bac *BatchApplyCall) readCheckpoint() (*batchCheckpoint, error) {
+	cp := &batchCheckpoint {
+		Operation: bac.op,
+		Done: make(map[string]int64),
+	}
+	if len(bac.checkpoint) == 0 {
+		return cp, nil
+	}
+
+	b, err := ioutil.ReadFile(bac.checkpoint)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return cp, nil
+		}
+		bac.Service.log().Error("read checkpoint file failed", LogError, err)
+		return nil, errors.New("Read checkpoint file failed.")
+	}
+
+	var saved batchCheckpoint
+	if err := json.Unmarshal(b, &saved); err != nil {
+		bac.Service.log().Error("unmarshal json failed", LogError, err)
+		return nil, errors.New("Unmarshal checkpoint json failed.")
+	}
+
+	want, _ := json.Marshal(bac.op)
+	got, _ := json.Marshal(saved.Operation)
+	if !bytes.Equal(want, got) {
+		bac.Service.log().Error("ignores checkpoint of another operation")
+		return cp, nil
+	}
+	if saved.Done != nil {
+		cp.Done = saved.Done
+	}
+
+	return cp, nil
+}
+
+// 儲存檢查點。
+func (bac *BatchApplyCall) saveCheckpoint(cp *batchCheckpoint) {
+	if len(bac.checkpoint) == 0 {
+		return
+	}
+
+	b, err := json.MarshalIndent(cp, "", " ")
+	if err != nil {
+		bac.Service.log().Error("marshal json failed", LogError, err)
+		return
+	}
+
+	if err := ioutil.WriteFile(bac.checkpoint, b, 0644); err != nil {
+		bac.Service.log().Error("write checkpoint file failed", LogError, err)
+	}
+}
+
+// 是否包含相同的 DNSSEC 記錄。
+func containsDNSSEC(records []DNSSEC, record DNSSEC) bool {
+	for _, r := range records {
+		if r.KeyTag == record.KeyTag && r.Algorithm == record.Algorithm && strings.EqualFold(r.Digest, record.Digest) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// 讀取 zone 清單檔案，一行一個 zone，忽略空行和 # 開頭的註解。
+func ReadZoneList(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		defaultLogger().Error("open zone list file failed", LogError, err)
+		return nil, errors.New("Open zone list file failed.")
+	}
+	defer file.Close()
+
+	zones := make([]string, 0)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		zones = append(zones, line)
+	}
+	if err := scanner.Err(); err != nil {
+		defaultLogger().Error("scan zone list file failed", LogError, err)
+		return nil, errors.New("Read zone list file failed.")
+	}
+
+	return zones, nil
+}
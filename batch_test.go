@@ -0,0 +1,102 @@
+package pchome
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestBatchCheckpoint(t *testing.T) {
+	dir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	tmp := t.TempDir()
+	if err := os.Chdir(tmp); err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Chdir(dir)
+
+	config := Config {
+		Zones: map[string]Zone {
+			"a.example": {NS: NS{"ns0.example.net": ""}},
+			"b.example": {NS: NS{"ns0.example.net": ""}},
+			"c.example": {NS: NS{"ns0.example.net": ""}},
+		},
+	}
+	if err := NewConfigService().Save(&config); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// 第一次執行時 c.example 失敗，模擬中斷。
+	var mu sync.Mutex
+	posts := make(map[string]int)
+	broken := "c.example"
+	s := NewService("key")
+	s.Limiter = nil
+	s.Client = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		req.ParseForm()
+		zone := req.PostForm.Get("dn")
+
+		mu.Lock()
+		posts[zone]++
+		status := http.StatusOK
+		if zone == broken {
+			status = http.StatusInternalServerError
+		}
+		mu.Unlock()
+
+		return &http.Response {
+			StatusCode: status,
+			Header: http.Header{"Content-Type": []string{"text/html; charset=utf-8"}},
+			Body: ioutil.NopCloser(bytes.NewReader(nil)),
+			Request: req,
+		}, nil
+	})}
+
+	zones := []string{"a.example", "b.example", "c.example"}
+	op := BatchOperation{NS: NS{"ns1.example.net": ""}}
+	checkpoint := filepath.Join(tmp, "checkpoint")
+
+	report, err := s.NewBatchService().Apply(zones, op).Checkpoint(checkpoint).Do()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if report.Succeeded != 2 || report.Failed != 1 {
+		t.Fatalf("First run succeeded %d and failed %d.", report.Succeeded, report.Failed)
+	}
+	if _, err := os.Stat(checkpoint); err != nil {
+		t.Fatalf("No checkpoint after a failed run, %s.", err.Error())
+	}
+
+	// 另一個操作不會沿用這個檢查點。
+	other := BatchOperation{NS: NS{"ns2.example.net": ""}}
+	if cp, err := s.NewBatchService().Apply(zones, other).Checkpoint(checkpoint).readCheckpoint(); err != nil || len(cp.Done) != 0 {
+		t.Errorf("Another operation resumes %v, %v.", cp.Done, err)
+	}
+
+	mu.Lock()
+	broken = ""
+	for zone := range posts {
+		posts[zone] = 0
+	}
+	mu.Unlock()
+
+	report, err = s.NewBatchService().Apply(zones, op).Checkpoint(checkpoint).Do()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if report.Succeeded != 1 || report.Skipped != 2 || report.Failed != 0 {
+		t.Errorf("Second run succeeded %d, skipped %d and failed %d.", report.Succeeded, report.Skipped, report.Failed)
+	}
+	if posts["a.example"] != 0 || posts["b.example"] != 0 || posts["c.example"] != 1 {
+		t.Errorf("Second run posted %v, want only c.example.", posts)
+	}
+	if _, err := os.Stat(checkpoint); !os.IsNotExist(err) {
+		t.Errorf("Checkpoint is kept after a successful run, %v.", err)
+	}
+}
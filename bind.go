@@ -0,0 +1,236 @@
+package pchome
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 匯出的預設 TTL，PChome 沒有 TTL。
+const BINDTTL = 86400
+
+// 以 RFC 1035 主檔格式匯出 zone 的委派，依 zone 名稱排序。
+// 每個 zone 輸出 NS、zone 之內主機的 glue A 或 AAAA 和 DS，DS 的 digest type 依 digest 長度推測。
+// zone 之外主機的 IP 不是 glue，以註解輸出。
+func WriteBIND(w io.Writer, zones map[string]Zone) error {
+	names := make([]string, 0, len(zones))
+	for name := range zones {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "$TTL %d\n", BINDTTL)
+	for _, name := range names {
+		zone := zones[name]
+		fmt.Fprintf(bw, "\n; %s", name)
+		if zone.UpdatedAt > 0 {
+			fmt.Fprintf(bw, ", synchronized %s", time.Unix(zone.UpdatedAt, 0).UTC().Format(time.RFC3339))
+		}
+		fmt.Fprintln(bw)
+
+		hosts := make([]string, 0, len(zone.NS))
+		for host := range zone.NS {
+			hosts = append(hosts, host)
+		}
+		sort.Strings(hosts)
+		for _, host := range hosts {
+			fmt.Fprintf(bw, "%s.\tIN\tNS\t%s.\n", name, host)
+		}
+		for _, host := range hosts {
+			ipv4, ipv6 := SplitGlue(zone.NS[host])
+			comment := ""
+			if !inBailiwick(host, name) {
+				comment = "; "
+			}
+			if len(ipv4) > 0 {
+				fmt.Fprintf(bw, "%s%s.\tIN\tA\t%s\n", comment, host, ipv4)
+			}
+			if len(ipv6) > 0 {
+				fmt.Fprintf(bw, "%s%s.\tIN\tAAAA\t%s\n", comment, host, ipv6)
+			}
+		}
+		for _, r := range zone.DNSSEC {
+			fmt.Fprintf(bw, "%s.\tIN\tDS\t%s\n", name, FormatDS(r))
+		}
+	}
+
+	return bw.Flush()
+}
+
+// 讀取 WriteBIND 格式的委派作為期望的狀態，zone 為 NS 和 DS 記錄的擁有者。
+// 支援 $ORIGIN、$TTL、相對名稱、省略擁有者和括號跨行，TTL 和 class 會被忽略。
+// NS 主機的 A 和 AAAA 記錄作為 glue，同一主機有兩者時以 JoinGlue 合併。
+// 只有 DS 的 zone 的 NS 為 nil，表示不管理 NS，和 Portfolio.Zones 相同。
+func ReadBIND(r io.Reader) (map[string]Zone, error) {
+	zones := make(map[string]Zone)
+	glue := make(map[string][2]string)
+	origin, owner := "", ""
+
+	scanner := bufio.NewScanner(r)
+	line, lineNo, depth := "", 0, 0
+	for scanner.Scan() {
+		lineNo++
+		text := scanner.Text()
+		if i := strings.Index(text, ";"); i >= 0 {
+			text = text[:i]
+		}
+		if depth == 0 {
+			line = text
+		} else {
+			line += " " + text
+		}
+		depth += strings.Count(text, "(") - strings.Count(text, ")")
+		if depth > 0 {
+			continue
+		}
+		if depth < 0 {
+			return nil, bindError(lineNo, "unbalanced parentheses")
+		}
+
+		blankOwner := len(line) > 0 && (line[0] == ' ' || line[0] == '\t')
+		fields := strings.Fields(strings.NewReplacer("(", " ", ")", " ").Replace(line))
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch strings.ToUpper(fields[0]) {
+		case "$ORIGIN":
+			if len(fields) != 2 {
+				return nil, bindError(lineNo, "bad $ORIGIN")
+			}
+			if !strings.HasSuffix(fields[1], ".") {
+				return nil, bindError(lineNo, "$ORIGIN must be an absolute name")
+			}
+			origin = absName(fields[1], origin)
+			continue
+		case "$TTL":
+			continue
+		case "$INCLUDE", "$GENERATE":
+			return nil, bindError(lineNo, fields[0] + " is not supported")
+		}
+
+		if !blankOwner {
+			if !absolute(fields[0], origin) {
+				return nil, bindError(lineNo, "relative name " + fields[0] + " without $ORIGIN")
+			}
+			owner = absName(fields[0], origin)
+			fields = fields[1:]
+		}
+		if len(owner) == 0 {
+			return nil, bindError(lineNo, "no owner name")
+		}
+
+		// 略過 TTL 和 class。
+		for len(fields) > 0 {
+			if _, err := strconv.ParseUint(fields[0], 10, 32); err == nil || strings.EqualFold(fields[0], "IN") {
+				fields = fields[1:]
+				continue
+			}
+			break
+		}
+		if len(fields) < 2 {
+			return nil, bindError(lineNo, "no record data")
+		}
+
+		typ, data := strings.ToUpper(fields[0]), fields[1:]
+		switch typ {
+		case "NS":
+			if len(data) != 1 || !absolute(data[0], origin) {
+				return nil, bindError(lineNo, "bad NS record")
+			}
+			host := absName(data[0], origin)
+			if !ValidHost(host) {
+				return nil, bindError(lineNo, "bad name server " + data[0])
+			}
+			zone := zones[owner]
+			if zone.NS == nil {
+				zone.NS = make(NS)
+			}
+			if len(zone.NS) == 5 {
+				return nil, bindError(lineNo, "more than 5 NS records for " + owner)
+			}
+			zone.NS[host] = ""
+			zones[owner] = zone
+		case "A", "AAAA":
+			ip := net.ParseIP(data[0])
+			if len(data) != 1 || ip == nil || (typ == "A") != (ip.To4() != nil) {
+				return nil, bindError(lineNo, "bad " + typ + " record")
+			}
+			addrs := glue[owner]
+			if typ == "A" {
+				addrs[0] = data[0]
+			} else {
+				addrs[1] = data[0]
+			}
+			glue[owner] = addrs
+		case "DS":
+			record, err := ParseDS(strings.Join(data, " "))
+			if err != nil {
+				return nil, bindError(lineNo, strings.TrimSuffix(err.Error(), "."))
+			}
+			record.Digest = strings.ToUpper(record.Digest)
+			zone := zones[owner]
+			if len(zone.DNSSEC) == 5 {
+				return nil, bindError(lineNo, "more than 5 DS records for " + owner)
+			}
+			zone.DNSSEC = append(zone.DNSSEC, record)
+			zones[owner] = zone
+		default:
+			return nil, bindError(lineNo, "unsupported record type " + typ)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if depth > 0 {
+		return nil, bindError(lineNo, "unbalanced parentheses")
+	}
+
+	for name, zone := range zones {
+		for host := range zone.NS {
+			zone.NS[host] = JoinGlue(glue[host][0], glue[host][1])
+		}
+		if zone.DNSSEC == nil {
+			zone.DNSSEC = make([]DNSSEC, 0)
+			zones[name] = zone
+		}
+	}
+
+	return zones, nil
+}
+
+// 主機是否在 zone 之內。
+func inBailiwick(host, zone string) bool {
+	return host == zone || strings.HasSuffix(host, "." + zone)
+}
+
+// 名稱是否能轉成絕對名稱。
+func absolute(name, origin string) bool {
+	return strings.HasSuffix(name, ".") || len(origin) > 0
+}
+
+// 把名稱轉成不含結尾點的小寫絕對名稱，@ 為 origin。
+func absName(name, origin string) string {
+	name = strings.ToLower(name)
+	switch {
+	case name == "@":
+		return origin
+	case strings.HasSuffix(name, "."):
+		return strings.TrimSuffix(name, ".")
+	case len(origin) == 0:
+		return name
+	}
+
+	return name + "." + origin
+}
+
+func bindError(line int, msg string) error {
+	return errors.New("Line " + strconv.Itoa(line) + ": " + msg + ".")
+}
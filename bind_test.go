@@ -0,0 +1,110 @@
+package pchome
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadBIND(t *testing.T) {
+	f, err := os.Open("testdata/delegation.zone")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer f.Close()
+
+	zones, err := ReadBIND(f)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	want := map[string]Zone {
+		"example.com": {
+			NS: NS{"ns1.example.com": "192.0.2.1,2001:db8::1", "ns2.example.net": ""},
+			DNSSEC: []DNSSEC{{KeyTag: 2371, Algorithm: 13, Digest: strings.Repeat("0123456789ABCDEF", 4)}},
+		},
+		"example.org": {
+			NS: NS{"ns1.example.org": "2001:db8::53"},
+			DNSSEC: []DNSSEC{{KeyTag: 12345, Algorithm: 8, Digest: strings.Repeat("AB", 16) + "ABCDEFAB"}},
+		},
+	}
+	if !reflect.DeepEqual(zones, want) {
+		t.Errorf("Read %+v, want %+v.", zones, want)
+	}
+
+	for _, bad := range []string {
+		"example.com. IN DS 1 13 1 " + strings.Repeat("A", 64),
+		"example.com. IN MX 10 mail.example.com.",
+		"example.com. IN NS ( ns1.example.net.",
+		"ns1 IN A 192.0.2.1",
+	} {
+		if _, err := ReadBIND(strings.NewReader(bad)); err == nil {
+			t.Errorf("Reading %q succeeds.", bad)
+		}
+	}
+}
+
+func TestWriteBIND(t *testing.T) {
+	zones := map[string]Zone {
+		"example.com": {
+			NS: NS{"ns1.example.com": "2001:db8::1", "ns2.example.net": "192.0.2.2"},
+			DNSSEC: []DNSSEC{{KeyTag: 2371, Algorithm: 13, Digest: strings.Repeat("A", 96)}},
+			UpdatedAt: 1700000000,
+		},
+		"example.org": {NS: NS{"ns1.example.net": ""}, DNSSEC: []DNSSEC{}},
+		"example.net": {DNSSEC: []DNSSEC{{KeyTag: 1, Algorithm: 8, Digest: strings.Repeat("B", 64)}}},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteBIND(&buf, zones); err != nil {
+		t.Fatal(err.Error())
+	}
+	text := buf.String()
+	for _, line := range []string {
+		"; example.com, synchronized 2023-11-14T22:13:20Z\n",
+		"example.com.\tIN\tNS\tns1.example.com.\n",
+		"ns1.example.com.\tIN\tAAAA\t2001:db8::1\n",
+		"; ns2.example.net.\tIN\tA\t192.0.2.2\n",
+		"example.com.\tIN\tDS\t2371 13 4 " + strings.Repeat("A", 96) + "\n",
+		"example.org.\tIN\tNS\tns1.example.net.\n",
+	} {
+		if !strings.Contains(text, line) {
+			t.Errorf("Missing %q in\n%s", line, text)
+		}
+	}
+
+	// ReadBIND 不會讀回 zone 之外主機的 IP 和同步時間，匯入指令沿用組態裡的 IP。
+	read, err := ReadBIND(&buf)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	com := read["example.com"]
+	if com.NS["ns1.example.com"] != "2001:db8::1" || com.NS["ns2.example.net"] != "" || !reflect.DeepEqual(com.DNSSEC, zones["example.com"].DNSSEC) {
+		t.Errorf("Read back %+v.", com)
+	}
+	if !reflect.DeepEqual(read["example.org"], zones["example.org"]) {
+		t.Errorf("Read back %+v.", read["example.org"])
+	}
+	// 沒有 NS 的 zone 讀回不管理 NS。
+	if net := read["example.net"]; net.NS != nil || !reflect.DeepEqual(net.DNSSEC, zones["example.net"].DNSSEC) {
+		t.Errorf("Read back %+v.", net)
+	}
+}
+
+func TestParseDS(t *testing.T) {
+	digest := strings.Repeat("ab", 32)
+	r, err := ParseDS("2371 13 2 " + digest[:32] + " " + digest[32:])
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if r != (DNSSEC{KeyTag: 2371, Algorithm: 13, Digest: digest}) || FormatDS(r) != "2371 13 2 " + digest {
+		t.Errorf("Got %+v, formatted %q.", r, FormatDS(r))
+	}
+
+	for _, data := range []string{"2371 13 2", "x 13 2 " + digest, "2371 13 1 " + digest, "2371 13 2 " + strings.Repeat("zz", 32)} {
+		if _, err := ParseDS(data); err == nil {
+			t.Errorf("Parsing %q succeeds.", data)
+		}
+	}
+}
@@ -0,0 +1,283 @@
+package pchome
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"errors"
+)
+
+// 遮蔽敏感資料的替代值。
+const redacted = "REDACTED"
+
+// 錄製的 HTTP 請求。
+type CassetteRequest struct {
+	Method string
+	URL string
+	Header http.Header
+	Body []byte
+}
+
+// 錄製的 HTTP 回應。
+type CassetteResponse struct {
+	StatusCode int
+	Header http.Header
+	Body []byte
+}
+
+// 錄製的一次 HTTP 交換。
+type Interaction struct {
+	Request CassetteRequest
+	Response CassetteResponse
+}
+
+// 錄影帶，依序記錄一次 session 的所有 HTTP 交換。
+type Cassette struct {
+	Interactions []Interaction
+}
+
+// 讀取錄影帶檔案。
+func ReadCassette(path string) (*Cassette, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		defaultLogger().Error("read cassette file failed", LogError, err)
+		return nil, errors.New("Read cassette file failed.")
+	}
+
+	var cassette Cassette
+	if err := json.Unmarshal(b, &cassette); err != nil {
+		defaultLogger().Error("unmarshal json failed", LogError, err)
+		return nil, errors.New("Unmarshal cassette json failed.")
+	}
+
+	return &cassette, nil
+}
+
+// 儲存錄影帶檔案。
+func (c *Cassette) Save(path string) error {
+	b, err := json.MarshalIndent(c, "", " ")
+	if err != nil {
+		defaultLogger().Error("marshal json failed", LogError, err)
+		return errors.New("Marshal json failed.")
+	}
+
+	if err := ioutil.WriteFile(path, b, 0600); err != nil {
+		defaultLogger().Error("write cassette file failed", LogError, err)
+		return errors.New("Writing cassette file failed.")
+	}
+
+	return nil
+}
+
+// 錄製器，把每次 HTTP 交換遮蔽 cookie 和密碼後寫入錄影帶檔案。
+type Recorder struct {
+	Path string
+	// 實際送出請求的 transport，nil 時使用 http.DefaultTransport。
+	Transport http.RoundTripper
+
+	mu sync.Mutex
+	cassette Cassette
+}
+
+// 取得錄製器。
+func NewRecorder(path string, transport http.RoundTripper) *Recorder {
+	return &Recorder {
+		Path: path,
+		Transport: transport,
+	}
+}
+
+// 送出請求並錄製。
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil {
+		b, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		reqBody = b
+		req.Body = ioutil.NopCloser(bytes.NewReader(b))
+	}
+
+	transport := r.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+
+	interaction := Interaction {
+		Request: CassetteRequest {
+			Method: req.Method,
+			URL: redactURL(req.URL),
+			Header: redactHeader(req.Header),
+			Body: redactForm(req.Header.Get("Content-Type"), reqBody),
+		},
+		Response: CassetteResponse {
+			StatusCode: resp.StatusCode,
+			Header: redactHeader(resp.Header),
+			Body: respBody,
+		},
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cassette.Interactions = append(r.cassette.Interactions, interaction)
+	if err := r.cassette.Save(r.Path); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// 重播器，依序回放錄影帶裡相同方法和網址的回應。
+type Replayer struct {
+	mu sync.Mutex
+	cassette *Cassette
+	used []bool
+}
+
+// 從錄影帶檔案取得重播器。
+func NewReplayer(path string) (*Replayer, error) {
+	cassette, err := ReadCassette(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Replayer {
+		cassette: cassette,
+		used: make([]bool, len(cassette.Interactions)),
+	}, nil
+}
+
+// 回放請求的錄製回應。
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	urlstr := redactURL(req.URL)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, interaction := range r.cassette.Interactions {
+		if r.used[i] || interaction.Request.Method != req.Method || interaction.Request.URL != urlstr {
+			continue
+		}
+		r.used[i] = true
+
+		header := http.Header{}
+		for k, v := range interaction.Response.Header {
+			header[k] = append([]string{}, v...)
+		}
+
+		return &http.Response {
+			Status: http.StatusText(interaction.Response.StatusCode),
+			StatusCode: interaction.Response.StatusCode,
+			Proto: "HTTP/1.1",
+			ProtoMajor: 1,
+			ProtoMinor: 1,
+			Header: header,
+			Body: ioutil.NopCloser(bytes.NewReader(interaction.Response.Body)),
+			ContentLength: int64(len(interaction.Response.Body)),
+			Request: req,
+		}, nil
+	}
+
+	return nil, errors.New("No recorded response for " + req.Method + " " + urlstr + ".")
+}
+
+// 是否為需要遮蔽的欄位名稱。
+func sensitive(name string) bool {
+	name = strings.ToLower(name)
+	return strings.Contains(name, "pass") || name == "loginkuser"
+}
+
+// 遮蔽 cookie 和認證標頭。
+func redactHeader(header http.Header) http.Header {
+	h := http.Header{}
+	for k, v := range header {
+		switch http.CanonicalHeaderKey(k) {
+		case "Cookie":
+			cookies := make([]string, 0)
+			for _, line := range v {
+				for _, part := range strings.Split(line, ";") {
+					kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+					if len(kv[0]) > 0 {
+						cookies = append(cookies, kv[0] + "=" + redacted)
+					}
+				}
+			}
+			h[k] = []string{strings.Join(cookies, "; ")}
+		case "Set-Cookie":
+			lines := make([]string, 0, len(v))
+			for _, line := range v {
+				attrs := strings.SplitN(line, ";", 2)
+				kv := strings.SplitN(attrs[0], "=", 2)
+				line = kv[0] + "=" + redacted
+				if len(attrs) > 1 {
+					line += ";" + attrs[1]
+				}
+				lines = append(lines, line)
+			}
+			h[k] = lines
+		case "Authorization", "Proxy-Authorization":
+			h[k] = []string{redacted}
+		default:
+			h[k] = append([]string{}, v...)
+		}
+	}
+
+	return h
+}
+
+// 遮蔽網址參數裡的密碼。
+func redactURL(u *url.URL) string {
+	query := u.Query()
+	changed := false
+	for k := range query {
+		if sensitive(k) {
+			query.Set(k, redacted)
+			changed = true
+		}
+	}
+	if !changed {
+		return u.String()
+	}
+
+	c := *u
+	c.RawQuery = query.Encode()
+	return c.String()
+}
+
+// 遮蔽表單裡的密碼。
+func redactForm(contentType string, body []byte) []byte {
+	if !strings.HasPrefix(contentType, "application/x-www-form-urlencoded") {
+		return body
+	}
+
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		return body
+	}
+	for k := range form {
+		if sensitive(k) {
+			form.Set(k, redacted)
+		}
+	}
+
+	return []byte(form.Encode())
+}
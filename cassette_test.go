@@ -0,0 +1,66 @@
+package pchome
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecordReplay(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "loginkuser", Value: "secret-key"})
+		w.Write(fixture(t, "ns_full.html"))
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	client := &http.Client{Transport: NewRecorder(path, nil)}
+	form := url.Values{"mbrid": []string{"user@example.com"}, "mbrpass": []string{"secret-password"}}
+	resp, err := client.PostForm(server.URL + "/login", form)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	resp.Body.Close()
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if strings.Contains(string(b), "secret-key") || strings.Contains(string(b), "secret-password") {
+		t.Error("Cassette leaks secrets.")
+	}
+
+	replayer, err := NewReplayer(path)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	s := NewService("key")
+	s.Client = &http.Client{Transport: replayer}
+
+	req, err := http.NewRequest("POST", server.URL + "/login", strings.NewReader(form.Encode()))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	resp, err = s.Do(req)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	b, err = s.readBody(resp)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	record, err := s.NewNSService().parse(b)
+	if err != nil || len(record) != 5 {
+		t.Errorf("Replayed page has records %v, %v.", record, err)
+	}
+
+	if _, err = s.Do(req); err == nil {
+		t.Error("Replaying an exhausted cassette should fail.")
+	}
+}
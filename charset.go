@@ -0,0 +1,159 @@
+package pchome
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"unicode/utf8"
+	"errors"
+
+	"golang.org/x/net/html/charset"
+)
+
+// PChome 舊網頁使用的編碼，無法判斷編碼又不是 UTF-8 時使用。
+const legacyCharset = "big5"
+
+// 網頁編碼快取，記錄每個主機最後讀取網頁的編碼。
+type charsetCache struct {
+	mu sync.Mutex
+	names map[string]string
+}
+
+// 取得主機的網頁編碼，未知時回傳空字串。
+func (cc *charsetCache) get(host string) string {
+	if cc == nil {
+		return ""
+	}
+
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	return cc.names[host]
+}
+
+// 記錄主機的網頁編碼。
+func (cc *charsetCache) set(host, name string) {
+	if cc == nil {
+		return
+	}
+
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	cc.names[host] = name
+}
+
+// 判斷網頁編碼，依序參考 BOM、Content-Type 和 meta charset。
+// 都沒有時，合法的 UTF-8 視為 UTF-8，否則視為 Big5。
+func pageCharset(raw []byte, contentType string) string {
+	_, name, certain := charset.DetermineEncoding(raw, contentType)
+	if certain || name != "windows-1252" {
+		return name
+	}
+
+	if utf8.Valid(raw) {
+		return "utf-8"
+	}
+
+	return legacyCharset
+}
+
+// 把網頁內容從 name 編碼轉成 UTF-8。
+func decodePage(raw []byte, name string) ([]byte, error) {
+	if name == "utf-8" {
+		return raw, nil
+	}
+
+	enc, _ := charset.Lookup(name)
+	if enc == nil {
+		return nil, errors.New("Unknown page charset " + name + ".")
+	}
+
+	b, err := enc.NewDecoder().Bytes(raw)
+	if err != nil {
+		return nil, errors.New("Decoding " + name + " page failed, " + err.Error() + ".")
+	}
+
+	return b, nil
+}
+
+// 以 name 編碼表單資料，和 url.Values.Encode 一樣依欄位名稱排序。
+func encodeForm(data url.Values, name string) (string, error) {
+	if len(name) == 0 || name == "utf-8" {
+		return data.Encode(), nil
+	}
+
+	enc, _ := charset.Lookup(name)
+	if enc == nil {
+		return "", errors.New("Unknown form charset " + name + ".")
+	}
+	encoder := enc.NewEncoder()
+
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	for _, k := range keys {
+		ek, err := encoder.String(k)
+		if err != nil {
+			return "", errors.New("Encoding " + name + " form failed, " + err.Error() + ".")
+		}
+
+		for _, v := range data[k] {
+			ev, err := encoder.String(v)
+			if err != nil {
+				return "", errors.New("Encoding " + name + " form failed, " + err.Error() + ".")
+			}
+
+			if buf.Len() > 0 {
+				buf.WriteByte('&')
+			}
+			buf.WriteString(url.QueryEscape(ek))
+			buf.WriteByte('=')
+			buf.WriteString(url.QueryEscape(ev))
+		}
+	}
+
+	return buf.String(), nil
+}
+
+// 讀取回應內容，依網頁編碼轉成 UTF-8 並記錄該主機的編碼。
+func (s *Service) readBody(resp *http.Response) ([]byte, error) {
+	raw, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		s.log().Error("reads http body failed", LogError, err)
+		return nil, errors.New("Reading http body failed.")
+	}
+
+	name := pageCharset(raw, resp.Header.Get("Content-Type"))
+	if resp.Request != nil {
+		s.charsets.set(resp.Request.URL.Host, name)
+	}
+
+	return decodePage(raw, name)
+}
+
+// 取得提交表單的編碼。
+// 依序使用 Service.Charset、最後讀取網頁的編碼和 fallback，都沒有時使用 UTF-8。
+func (s *Service) formCharset(host, fallback string) string {
+	if len(s.Charset) > 0 {
+		return s.Charset
+	}
+	if name := s.charsets.get(host); len(name) > 0 {
+		return name
+	}
+	if len(fallback) > 0 {
+		return fallback
+	}
+
+	return "utf-8"
+}
+
+// 取得 PChome 網頁的編碼，還沒讀取過網頁時回傳空字串。
+func (s *Service) PageCharset() string {
+	return s.charsets.get(hostOf(ENDPOINT))
+}
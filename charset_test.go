@@ -0,0 +1,79 @@
+package pchome
+
+import (
+	"io/ioutil"
+	"net/url"
+	"testing"
+
+	"golang.org/x/text/encoding/traditionalchinese"
+)
+
+func TestDecodePage(t *testing.T) {
+	cases := []struct {
+		file string
+		contentType string
+		charset string
+	}{
+		{"testdata/zone_list_utf8.html", "text/html", "utf-8"},
+		{"testdata/zone_list_big5.html", "text/html", "big5"},
+		{"testdata/zone_list_big5_header.html", "text/html; charset=big5", "big5"},
+		{"testdata/zone_list_big5_header.html", "", "big5"},
+	}
+
+	for _, c := range cases {
+		raw, err := ioutil.ReadFile(c.file)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		name := pageCharset(raw, c.contentType)
+		if name != c.charset {
+			t.Errorf("%s: charset %s, want %s.", c.file, name, c.charset)
+			continue
+		}
+
+		b, err := decodePage(raw, name)
+		if err != nil {
+			t.Errorf("%s: %s", c.file, err.Error())
+			continue
+		}
+
+		zones, err := (&ZoneListCall{}).Parse(b)
+		if err != nil {
+			t.Errorf("%s: %s", c.file, err.Error())
+			continue
+		}
+		for _, zone := range []string{"example.com", "example.com.tw", "xn--fiq228c.tw"} {
+			if _, ok := zones[zone]; !ok {
+				t.Errorf("%s: missing zone %s.", c.file, zone)
+			}
+		}
+	}
+}
+
+func TestEncodeForm(t *testing.T) {
+	data := url.Values{
+		"dn": []string{"example.com"},
+		"fwd_titlef0": []string{"範例"},
+	}
+
+	if body, err := encodeForm(data, "utf-8"); err != nil || body != data.Encode() {
+		t.Errorf("utf-8 form %q, %v.", body, err)
+	}
+
+	body, err := encodeForm(data, "big5")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	values, err := url.ParseQuery(body)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	title, err := traditionalchinese.Big5.NewDecoder().String(values.Get("fwd_titlef0"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if title != "範例" || values.Get("dn") != "example.com" {
+		t.Errorf("big5 form decodes to %q and %q.", title, values.Get("dn"))
+	}
+}
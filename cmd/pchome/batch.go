@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/a2n/pchome"
+)
+
+// batch 指令，對多個 zone 套用同一組 NS 或 DS 變更。
+func batch(args []string) error {
+	fs := flag.NewFlagSet("batch", flag.ExitOnError)
+	sel := addSelection(fs)
+	file := fs.String("file", "", "file of zone names, one per line")
+	nsFlag := fs.String("ns", "", "replace NS records, e.g. ns0.example.com=10.0.0.0,ns1.example.com=10.0.0.1")
+	dsFlag := fs.String("ds", "", "replace DS records, keyTag:algorithm:digest separated by comma, '-' for none")
+	addDS := fs.String("ds-add", "", "add DS records, keyTag:algorithm:digest separated by comma")
+	deleteDS := fs.String("ds-delete", "", "delete DS records, keyTag:algorithm:digest separated by comma")
+	concurrency := fs.Int("concurrency", pchome.DefaultSyncOptions.Concurrency, "zones changed at the same time")
+	checkpoint := fs.String("checkpoint", ".pchome-batch", "checkpoint file to resume an interrupted run")
+	reportPath := fs.String("report", "", "write the JSON report to this file")
+	fs.Parse(args)
+
+	var op pchome.BatchOperation
+	var err error
+	if len(*nsFlag) > 0 {
+		if op.NS, err = parseNS(*nsFlag); err != nil {
+			return err
+		}
+	}
+	if *dsFlag == "-" {
+		op.DNSSEC = []pchome.DNSSEC{}
+	} else if len(*dsFlag) > 0 {
+		if op.DNSSEC, err = parseDSList(*dsFlag); err != nil {
+			return err
+		}
+	}
+	if op.AddDNSSEC, err = parseDSList(*addDS); err != nil {
+		return err
+	}
+	if op.DeleteDNSSEC, err = parseDSList(*deleteDS); err != nil {
+		return err
+	}
+	if op.NS == nil && op.DNSSEC == nil && len(op.AddDNSSEC) == 0 && len(op.DeleteDNSSEC) == 0 {
+		fs.Usage()
+		return errors.New("No operation.")
+	}
+
+	s, err := service()
+	if err != nil {
+		return err
+	}
+
+	var zones []string
+	if len(*file) > 0 {
+		if zones, err = pchome.ReadZoneList(*file); err != nil {
+			return err
+		}
+	} else {
+		if !sel.multiple() && len(*sel.zone) == 0 {
+			return errors.New("Select zones with -zone, -match, -glob, -tld or -file.")
+		}
+		if zones, err = sel.zones(s); err != nil {
+			return err
+		}
+	}
+	if err := confirm(zones, *sel.yes); err != nil {
+		return err
+	}
+
+	var report *pchome.BatchReport
+	err = withLock(func() (err error) {
+		report, err = s.NewBatchService().Apply(zones, op).
+			Concurrency(*concurrency).
+			Checkpoint(*checkpoint).
+			Progress(func(r pchome.BatchResult) {
+				switch {
+				case r.Skipped:
+					fmt.Printf("%s\tskipped\n", r.Zone)
+				case len(r.Error) > 0:
+					fmt.Printf("%s\tfailed\t%s\n", r.Zone, r.Error)
+				default:
+					fmt.Printf("%s\tok\n", r.Zone)
+				}
+			}).
+			Do()
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%d succeeded, %d failed, %d skipped.\n", report.Succeeded, report.Failed, report.Skipped)
+
+	if len(*reportPath) > 0 {
+		b, err := json.MarshalIndent(report, "", " ")
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(*reportPath, b, 0644); err != nil {
+			return err
+		}
+	}
+
+	if report.Failed > 0 {
+		return fmt.Errorf("%d zones failed, run again to resume.", report.Failed)
+	}
+
+	return nil
+}
+
+// 解析 name=ip 以逗號分隔的 NS 記錄。
+func parseNS(s string) (pchome.NS, error) {
+	record := make(pchome.NS)
+	for _, item := range strings.Split(s, ",") {
+		kv := strings.SplitN(strings.TrimSpace(item), "=", 2)
+		if len(kv) != 2 || len(kv[0]) == 0 {
+			return nil, fmt.Errorf("Bad NS record %q, want name=ip.", item)
+		}
+		record[kv[0]] = kv[1]
+	}
+
+	return record, nil
+}
+
+// 解析 keyTag:algorithm:digest 以逗號分隔的 DS 記錄。
+func parseDSList(s string) ([]pchome.DNSSEC, error) {
+	records := make([]pchome.DNSSEC, 0)
+	if len(s) == 0 {
+		return records, nil
+	}
+
+	for _, item := range strings.Split(s, ",") {
+		parts := strings.Split(strings.TrimSpace(item), ":")
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("Bad DS record %q, want keyTag:algorithm:digest.", item)
+		}
+
+		keyTag, err := strconv.ParseUint(parts[0], 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("Bad key tag %q.", parts[0])
+		}
+		algorithm, err := strconv.ParseUint(parts[1], 10, 8)
+		if err != nil {
+			return nil, fmt.Errorf("Bad algorithm %q.", parts[1])
+		}
+
+		records = append(records, pchome.DNSSEC {
+			KeyTag: uint16(keyTag),
+			Algorithm: uint8(algorithm),
+			Digest: parts[2],
+		})
+	}
+
+	return records, nil
+}
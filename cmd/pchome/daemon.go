@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+	"time"
+
+	"github.com/a2n/pchome"
+)
+
+// daemon 指令，定期偵測漂移、同步組態並維持 session，收到 SIGINT 或 SIGTERM 時結束。
+func daemon(args []string) error {
+	fs := flag.NewFlagSet("daemon", flag.ExitOnError)
+	interval := fs.Duration("interval", time.Hour, "time between synchronizations")
+	keepalive := fs.Duration("keepalive", 10 * time.Minute, "time between session checks")
+	concurrency := fs.Int("concurrency", pchome.DefaultSyncOptions.Concurrency, "zones synchronized at the same time")
+	checkDNS := fs.Bool("dns", false, "check the published NS of each zone after synchronizing")
+	fs.Parse(args)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	cs := newConfigService()
+	cs.Sync.Concurrency = *concurrency
+	cs.Lock = withLock
+
+	syncTicker := time.NewTicker(*interval)
+	defer syncTicker.Stop()
+	keepTicker := time.NewTicker(*keepalive)
+	defer keepTicker.Stop()
+
+	logger.Info("daemon started", "interval", *interval, "keepalive", *keepalive)
+	cycle(ctx, cs, *checkDNS)
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info("daemon stopped")
+			return nil
+		case <-syncTicker.C:
+			cycle(ctx, cs, *checkDNS)
+		case <-keepTicker.C:
+			if _, err := cs.GetKey(); err != nil {
+				logger.Error("keep session alive failed", pchome.LogOperation, "keepalive", pchome.LogError, err)
+			}
+		}
+	}
+}
+
+// 同步組態並記錄漂移，最後檢查 DNS。只在寫入組態時持有組態鎖，對網站和 DNS 的請求不持有。
+// 每個 zone 只取得一次。失敗只記錄錯誤，等下一輪再試。
+func cycle(ctx context.Context, cs *pchome.ConfigService, checkDNS bool) {
+	start := time.Now()
+	err := func() error {
+		changes, err := cs.UpdateChanges()
+		if syncErr, ok := err.(*pchome.SyncError); ok {
+			for name, err := range syncErr.Errors {
+				logger.Error("check drift failed", pchome.LogOperation, "drift", pchome.LogZone, name, pchome.LogError, err)
+			}
+		} else if err != nil {
+			return err
+		}
+
+		config, err := cs.Read()
+		if err != nil {
+			return err
+		}
+		drifted := make([]string, 0, len(changes))
+		for name, zoneChanges := range changes {
+			if _, ok := config.Zones[name]; !ok {
+				logger.Warn("drift detected", pchome.LogZone, name, "change", "zone removed")
+			}
+			for _, change := range zoneChanges {
+				logger.Warn("drift detected", pchome.LogZone, name, "change", change.String())
+			}
+			drifted = append(drifted, name)
+		}
+		if len(drifted) > 0 {
+			sort.Strings(drifted)
+			logger.Warn("zones drifted", pchome.LogOperation, "drift", "zones", drifted)
+		}
+
+		if !checkDNS {
+			return nil
+		}
+		for name := range config.Zones {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			cs.CheckDelegation(ctx, name)
+		}
+
+		return nil
+	}()
+	if err != nil {
+		logger.Error("synchronize failed", pchome.LogOperation, "sync", pchome.LogError, err)
+		return
+	}
+
+	logger.Info("synchronized", pchome.LogOperation, "sync", "duration", time.Since(start))
+}
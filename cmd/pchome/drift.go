@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/a2n/pchome"
+)
+
+// drift 指令的結束狀態。
+const (
+	exitDrift = 1
+	exitTrouble = 2
+)
+
+// 帶有結束狀態的錯誤。
+type exitError struct {
+	code int
+	msg string
+}
+
+func (e *exitError) Error() string {
+	return e.msg
+}
+
+// drift 指令，比較本地組態和網站上的記錄。
+// 沒有差異時結束狀態為 0，有差異為 1，無法比較為 2。
+func drift(args []string) error {
+	fs := flag.NewFlagSet("drift", flag.ExitOnError)
+	zones := fs.String("zones", "", "comma separated zone names to check, default all zones in the configuration")
+	concurrency := fs.Int("concurrency", pchome.DefaultSyncOptions.Concurrency, "zones checked at the same time")
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	fs.Parse(args)
+
+	cs := newConfigService()
+	cs.Sync.Concurrency = *concurrency
+	s, err := service()
+	if err != nil {
+		return &exitError{code: exitTrouble, msg: err.Error()}
+	}
+
+	var names []string
+	if len(*zones) > 0 {
+		names = splitZones(*zones)
+	}
+	report, err := cs.Drift(s, names)
+	if err != nil {
+		return &exitError{code: exitTrouble, msg: err.Error()}
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", " ")
+		if err := encoder.Encode(report); err != nil {
+			return &exitError{code: exitTrouble, msg: err.Error()}
+		}
+	} else {
+		for _, name := range report.Names() {
+			fmt.Println(name)
+			for _, change := range report.Zones[name] {
+				fmt.Printf("\t%s\n", change.String())
+			}
+		}
+	}
+
+	if len(report.Errors) > 0 {
+		failed := make([]string, 0, len(report.Errors))
+		for name := range report.Errors {
+			failed = append(failed, name)
+		}
+		sort.Strings(failed)
+		for _, name := range failed {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", name, report.Errors[name])
+		}
+		return &exitError{code: exitTrouble, msg: fmt.Sprintf("%d of %d zones could not be checked.", len(failed), report.Checked)}
+	}
+	if report.Drifted() {
+		return &exitError{code: exitDrift, msg: fmt.Sprintf("%d of %d zones drifted.", len(report.Zones), report.Checked)}
+	}
+
+	return nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/a2n/pchome"
+)
+
+// history 指令，列出稽核日誌。
+func history(args []string) error {
+	fs := flag.NewFlagSet("history", flag.ExitOnError)
+	zone := fs.String("zone", "", "only show changes of this zone")
+	asJSON := fs.Bool("json", false, "print entries as JSON lines")
+	fs.Parse(args)
+
+	entries, err := newConfigService().History(*zone)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		for _, entry := range entries {
+			if err := encoder.Encode(entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, entry := range entries {
+		fmt.Printf("%s\t%s\t%s\t%s\t%s", entry.Time.Format(time.RFC3339), entry.User, entry.Operation, entry.Zone, entry.Outcome)
+		if len(entry.Error) > 0 {
+			fmt.Printf("\t%s", entry.Error)
+		}
+		fmt.Println()
+		for _, change := range pchome.DiffZone(entry.Before, entry.After) {
+			fmt.Printf("\t%s\n", change.String())
+		}
+	}
+
+	return nil
+}
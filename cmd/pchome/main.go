@@ -0,0 +1,399 @@
+package main
+/*
+	pchome 指令
+ */
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/a2n/pchome"
+	"github.com/a2n/pchome/metrics"
+	"github.com/a2n/pchome/notify"
+)
+
+// 所有請求使用的 HTTP client，可以錄製或重播。
+var client = http.DefaultClient
+
+// 所有請求共用的限速器。
+var limiter = pchome.NewRateLimiter(pchome.DefaultRate, pchome.DefaultBurst)
+
+// 所有服務共用的記錄器，輸出到標準錯誤並遮蔽秘密。
+var logger *slog.Logger
+
+// 所有服務共用的觀察者，設定 -metrics 時才收集指標。
+var observer pchome.Observer
+
+// 等待組態鎖的時間。
+var lockWait time.Duration
+
+// 所有服務共用的通知者，設定 -notify 時才通知。
+var notifier pchome.Notifier
+
+func main() {
+	record := flag.String("record", "", "record HTTP exchanges into this cassette file")
+	replay := flag.String("replay", "", "replay HTTP exchanges from this cassette file")
+	rate := flag.Float64("rate", pchome.DefaultRate, "requests per second to PChome, 0 for unlimited")
+	burst := flag.Int("burst", pchome.DefaultBurst, "requests sent in a burst")
+	verbose := flag.Bool("v", false, "log debug messages, same as -log debug")
+	logLevel := flag.String("log", "warn", "log level, one of debug, info, warn and error")
+	flag.DurationVar(&lockWait, "lock-wait", pchome.DefaultLockWait, "time to wait for the configuration lock")
+	metricsAddr := flag.String("metrics", "", "serve Prometheus metrics on this address at /metrics")
+	notifyPath := flag.String("notify", "", "notify changes with the webhooks and mails in this JSON file")
+	flag.Usage = usage
+	flag.Parse()
+	limiter.SetRate(*rate, *burst)
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(*logLevel)); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(2)
+	}
+	if *verbose {
+		level = slog.LevelDebug
+	}
+	handler := slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level})
+	logger = slog.New(pchome.NewRedactHandler(handler))
+
+	if len(*notifyPath) > 0 {
+		n, err := notify.Load(*notifyPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		notifier = n
+	}
+
+	if len(*metricsAddr) > 0 {
+		collector := metrics.NewCollector()
+		observer = collector
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", collector.Handler())
+		go func() {
+			if err := http.ListenAndServe(*metricsAddr, mux); err != nil {
+				logger.Error("serve metrics failed", pchome.LogError, err)
+			}
+		}()
+	}
+
+	args := flag.Args()
+	if len(args) < 1 {
+		usage()
+		os.Exit(2)
+	}
+
+	switch {
+	case len(*record) > 0:
+		client = &http.Client{Transport: pchome.NewRecorder(*record, nil)}
+	case len(*replay) > 0:
+		replayer, err := pchome.NewReplayer(*replay)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		client = &http.Client{Transport: replayer}
+	}
+
+	var err error
+	switch args[0] {
+	case "config":
+		err = config(args[1:])
+	case "ns":
+		err = ns(args[1:])
+	case "dnssec":
+		err = dnssec(args[1:])
+	case "batch":
+		err = batch(args[1:])
+	case "history":
+		err = history(args[1:])
+	case "rollback":
+		err = rollback(args[1:])
+	case "drift":
+		err = drift(args[1:])
+	case "daemon":
+		err = daemon(args[1:])
+	case "notify":
+		err = notifyTest(args[1:])
+	case "serve":
+		err = serve(args[1:])
+	case "registrar":
+		err = registrar(args[1:])
+	case "export":
+		err = export(args[1:])
+	case "import":
+		err = importPortfolio(args[1:])
+	case "logout":
+		err = logout(args[1:])
+	default:
+		usage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		var exitErr *exitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.code)
+		}
+		os.Exit(1)
+	}
+}
+
+// 使用說明。
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: pchome [-record file | -replay file] [-rate n -burst n] [-v | -log level] [-metrics addr] [-notify file] [-lock-wait d] <config|ns|dnssec|batch|history|rollback|drift|daemon|notify|serve|registrar|export|import|logout> [flags]")
+}
+
+// 持有組態鎖執行 fn，避免和 daemon 或其他指令同時寫入組態。
+func withLock(fn func() error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), lockWait)
+	defer cancel()
+
+	lock, err := pchome.LockFile(ctx, pchome.DefaultLockPath)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	return fn()
+}
+
+// 取得使用共用 HTTP client 的組態服務。
+func newConfigService() *pchome.ConfigService {
+	cs := pchome.NewConfigService()
+	cs.Client = client
+	cs.Limiter = limiter
+	cs.Logger = logger
+	cs.Observer = observer
+	cs.Notifier = notifier
+	return cs
+}
+
+// 取得已登入的服務。
+func service() (*pchome.Service, error) {
+	key, err := newConfigService().GetKey()
+	if err != nil {
+		return nil, err
+	}
+
+	s := pchome.NewService(key)
+	s.Client = client
+	s.Limiter = limiter
+	s.Logger = logger
+	s.Observer = observer
+	s.Notifier = notifier
+	return s, nil
+}
+
+// config 指令。
+func config(args []string) error {
+	fs := flag.NewFlagSet("config", flag.ExitOnError)
+	initialize := fs.Bool("init", false, "initialize the configuration")
+	remove := fs.Bool("remove", false, "remove the configuration")
+	update := fs.Bool("update", false, "synchronize with PChome")
+	concurrency := fs.Int("concurrency", pchome.DefaultSyncOptions.Concurrency, "zones synchronized at the same time")
+	ttl := fs.Duration("ttl", 0, "skip zones synchronized within this duration")
+	zones := fs.String("zones", "", "comma separated zone names to update")
+	match := fs.String("match", "", "regex of zone names to update")
+	fs.Parse(args)
+
+	cs := newConfigService()
+	cs.Sync.Concurrency = *concurrency
+	cs.Sync.TTL = *ttl
+	cs.Sync.Progress = progress
+	if !*initialize && !*remove && !*update {
+		fs.Usage()
+		return nil
+	}
+
+	return withLock(func() error {
+		switch {
+		case *initialize:
+			return cs.Init()
+		case *remove:
+			return cs.Remove()
+		case *update && len(*zones) > 0:
+			s, err := service()
+			if err != nil {
+				return err
+			}
+			_, err = cs.UpdateZonesByNames(s, splitZones(*zones))
+			return err
+		case *update && len(*match) > 0:
+			re, err := regexp.Compile(*match)
+			if err != nil {
+				return err
+			}
+			s, err := service()
+			if err != nil {
+				return err
+			}
+			_, err = cs.UpdateZonesByRegexp(s, re)
+			return err
+		case *update:
+			return cs.Update()
+		}
+
+		return nil
+	})
+}
+
+// 印出同步進度。
+func progress(p pchome.SyncProgress) {
+	if p.Err != nil {
+		fmt.Printf("%d/%d) Failed to receive %s dns records, %s\n", p.Done, p.Total, p.Zone, p.Err.Error())
+		return
+	}
+
+	fmt.Printf("%d/%d) Received %s dns records.\n", p.Done, p.Total, p.Zone)
+}
+
+// ns 指令。
+func ns(args []string) error {
+	fs := flag.NewFlagSet("ns", flag.ExitOnError)
+	add := fs.Bool("add", false, "add a NS record")
+	del := fs.Bool("delete", false, "delete a NS record")
+	update := fs.Bool("update", false, "update a NS record")
+	list := fs.Bool("list", false, "list NS records")
+	sel := addSelection(fs)
+	name := fs.String("name", "", "host name")
+	ip := fs.String("ip", "", "host ip, ipv4,ipv6 for both")
+	fs.Parse(args)
+
+	s, err := service()
+	if err != nil {
+		return err
+	}
+
+	zones, err := sel.zones(s)
+	if err != nil {
+		return err
+	}
+
+	nss := s.NewNSService()
+	var op func(zone string) error
+	switch {
+	case *add:
+		op = func(zone string) error { return nss.Add(zone, *name, *ip) }
+	case *del:
+		op = func(zone string) error { return nss.Delete(zone, *name, *ip) }
+	case *update:
+		op = func(zone string) error { return nss.Update(zone, *name, *ip) }
+	case *list:
+		return each(zones, func(zone string) error {
+			record, err := nss.List(zone)
+			if err != nil {
+				return err
+			}
+
+			names := make([]string, 0, len(record))
+			for name := range record {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				if sel.multiple() {
+					fmt.Printf("%s\t", zone)
+				}
+				fmt.Printf("%s\t%s\n", name, record[name])
+			}
+			return nil
+		})
+	default:
+		fs.Usage()
+		return nil
+	}
+
+	if err := sel.confirm(zones); err != nil {
+		return err
+	}
+
+	return withLock(func() error { return each(zones, op) })
+}
+
+// dnssec 指令。
+func dnssec(args []string) error {
+	fs := flag.NewFlagSet("dnssec", flag.ExitOnError)
+	add := fs.Bool("add", false, "add a DNSSEC record")
+	del := fs.Bool("delete", false, "delete a DNSSEC record")
+	list := fs.Bool("list", false, "list DNSSEC records")
+	sel := addSelection(fs)
+	keyTagFlag := fs.String("keyTag", "0", "key tag")
+	algorithmFlag := fs.String("algorithm", "0", "algorithm")
+	digest := fs.String("digest", "", "digest")
+	fs.Parse(args)
+
+	var keyTag uint16
+	var algorithm uint8
+	if *add || *del {
+		n, err := strconv.ParseUint(*keyTagFlag, 10, 16)
+		if err != nil {
+			return fmt.Errorf("Bad key tag %q.", *keyTagFlag)
+		}
+		keyTag = uint16(n)
+		if n, err = strconv.ParseUint(*algorithmFlag, 10, 8); err != nil {
+			return fmt.Errorf("Bad algorithm %q.", *algorithmFlag)
+		}
+		algorithm = uint8(n)
+	}
+
+	s, err := service()
+	if err != nil {
+		return err
+	}
+
+	zones, err := sel.zones(s)
+	if err != nil {
+		return err
+	}
+
+	ds := s.NewDNSSECService()
+	var op func(zone string) error
+	switch {
+	case *add:
+		op = func(zone string) error { return ds.Add(zone, keyTag, algorithm, *digest) }
+	case *del:
+		op = func(zone string) error { return ds.Delete(zone, keyTag, algorithm, *digest) }
+	case *list:
+		return each(zones, func(zone string) error {
+			records, err := ds.List(zone)
+			if err != nil {
+				return err
+			}
+
+			for _, r := range records {
+				if sel.multiple() {
+					fmt.Printf("%s\t", zone)
+				}
+				fmt.Printf("%s\t%s\t%s\n", strconv.Itoa(int(r.KeyTag)), strconv.Itoa(int(r.Algorithm)), r.Digest)
+			}
+			return nil
+		})
+	default:
+		fs.Usage()
+		return nil
+	}
+
+	if err := sel.confirm(zones); err != nil {
+		return err
+	}
+
+	return withLock(func() error { return each(zones, op) })
+}
+
+// logout 指令，登出並清除 session 快取。
+func logout(args []string) error {
+	fs := flag.NewFlagSet("logout", flag.ExitOnError)
+	fs.Parse(args)
+
+	return newConfigService().Logout()
+}
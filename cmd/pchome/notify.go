@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"time"
+
+	"github.com/a2n/pchome"
+)
+
+// notify 指令，送出測試通知以確認 -notify 的設定。
+func notifyTest(args []string) error {
+	fs := flag.NewFlagSet("notify", flag.ExitOnError)
+	zone := fs.String("zone", "example.com", "zone name in the test event")
+	fs.Parse(args)
+
+	if notifier == nil {
+		return errors.New("No notifier, set one with -notify.")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30 * time.Second)
+	defer cancel()
+
+	return notifier.Notify(ctx, pchome.Event {
+		Type: pchome.EventChange,
+		Time: time.Now(),
+		Zone: *zone,
+		Operation: "notify.test",
+		Changes: []pchome.Change {
+			{Kind: "ns", Action: pchome.ChangeAdd, Name: "ns0." + *zone, After: "192.0.2.1"},
+		},
+	})
+}
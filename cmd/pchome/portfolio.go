@@ -0,0 +1,214 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/a2n/pchome"
+)
+
+// export 指令，把組態裡的 zone、NS、glue、DS 和最後同步時間匯出成試算表或其他工具可以讀的格式。
+func export(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	format := fs.String("format", pchome.FormatCSV, "csv, json, yaml or bind")
+	output := fs.String("o", "-", "output file, - for stdout")
+	zones := fs.String("zones", "", "comma separated zone names to export, default all")
+	fs.Parse(args)
+
+	config, err := newConfigService().Read()
+	if err != nil {
+		return err
+	}
+	selected := config.Zones
+	if len(*zones) > 0 {
+		selected = make(map[string]pchome.Zone)
+		for _, name := range splitZones(*zones) {
+			zone, ok := config.Zones[name]
+			if !ok {
+				return errors.New("No zone " + name + " in the configuration.")
+			}
+			selected[name] = zone
+		}
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "-" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	return pchome.WritePortfolio(w, pchome.NewPortfolio(selected), *format)
+}
+
+// import 指令，檢查匯出格式的檔案，列出和組態的差異，確認後以批次提交。
+// 檔案裡的每個 zone 為完整的期望狀態，沒有 NS 表示不管理 NS，沒有 DS 表示移除所有 DS。
+func importPortfolio(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	format := fs.String("format", "", "csv, json, yaml or bind, default by the file extension")
+	concurrency := fs.Int("concurrency", pchome.DefaultSyncOptions.Concurrency, "zones changed at the same time")
+	reportPath := fs.String("report", "", "write the JSON report to this file")
+	yes := fs.Bool("yes", false, "apply without confirmation")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: pchome import [flags] <file | ->")
+		fs.PrintDefaults()
+		return nil
+	}
+
+	path := fs.Arg(0)
+	if len(*format) == 0 {
+		*format = pchome.FormatOf(path)
+	}
+	if len(*format) == 0 {
+		return errors.New("Cannot tell the format of " + path + ", use -format.")
+	}
+
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	portfolio, err := pchome.ReadPortfolio(r, *format)
+	if err != nil {
+		return err
+	}
+	if err := portfolio.Validate(); err != nil {
+		return err
+	}
+
+	cs := newConfigService()
+	config, err := cs.Read()
+	if err != nil {
+		return err
+	}
+
+	// 依操作分組，相同操作的 zone 在同一個批次提交。
+	ops := make(map[string]pchome.BatchOperation)
+	groups := make(map[string][]string)
+	diffs := make(map[string][]pchome.Change)
+	names := make([]string, 0)
+	desired := portfolio.Desired(config.Zones)
+	if *format == pchome.FormatBIND {
+		keepOutOfZoneGlue(desired, config.Zones)
+	}
+	for name, zone := range desired {
+		current, ok := config.Zones[name]
+		if !ok {
+			return errors.New("No zone " + name + " in the configuration, run config -update first.")
+		}
+		changes := pchome.DiffZone(current, zone)
+		if len(changes) == 0 {
+			continue
+		}
+
+		var op pchome.BatchOperation
+		for _, change := range changes {
+			if change.Kind == "ns" {
+				op.NS = zone.NS
+			} else {
+				op.DNSSEC = zone.DNSSEC
+			}
+		}
+		b, _ := json.Marshal(op)
+		ops[string(b)] = op
+		groups[string(b)] = append(groups[string(b)], name)
+		diffs[name] = changes
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		fmt.Println("Nothing to change.")
+		return nil
+	}
+
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Println(name)
+		for _, change := range diffs[name] {
+			fmt.Printf("\t%s\n", change.String())
+		}
+	}
+	if err := confirm(names, *yes); err != nil {
+		return err
+	}
+
+	s, err := service()
+	if err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(groups))
+	for key := range groups {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	reports := make([]*pchome.BatchReport, 0, len(keys))
+	failed := 0
+	err = withLock(func() error {
+		for _, key := range keys {
+			sort.Strings(groups[key])
+			report, err := s.NewBatchService().Apply(groups[key], ops[key]).
+				Concurrency(*concurrency).
+				Progress(func(r pchome.BatchResult) {
+					if len(r.Error) > 0 {
+						fmt.Printf("%s\tfailed\t%s\n", r.Zone, r.Error)
+					} else {
+						fmt.Printf("%s\tok\n", r.Zone)
+					}
+				}).
+				Do()
+			if err != nil {
+				return err
+			}
+			reports = append(reports, report)
+			failed += report.Failed
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%d succeeded, %d failed.\n", len(names) - failed, failed)
+
+	if len(*reportPath) > 0 {
+		b, err := json.MarshalIndent(reports, "", " ")
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(*reportPath, b, 0644); err != nil {
+			return err
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d zones failed, run again to retry.", failed)
+	}
+
+	return nil
+}
+
+// BIND 不含 zone 之外主機的 IP，這些沒有 glue 的主機沿用 current 裡的 IP。
+func keepOutOfZoneGlue(zones, current map[string]pchome.Zone) {
+	for name, zone := range zones {
+		for host, glue := range zone.NS {
+			if len(glue) > 0 || host == name || strings.HasSuffix(host, "." + name) {
+				continue
+			}
+			zone.NS[host] = current[name].NS[host]
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	pchomereg "github.com/a2n/pchome/registrar"
+)
+
+// registrar 指令，依 dnscontrol 或 octoDNS 的設定更新委派的 name server 和 DS。
+func registrar(args []string) error {
+	fs := flag.NewFlagSet("registrar", flag.ExitOnError)
+	dnscontrol := fs.String("dnscontrol", "", "dnscontrol print-ir JSON file, - for stdin")
+	name := fs.String("name", "pchome", "dnscontrol registrar name, empty for all domains")
+	octodns := fs.String("octodns", "", "octoDNS YAML zone directory")
+	zones := fs.String("zones", "", "comma separated zone names to apply, default all")
+	yes := fs.Bool("yes", false, "apply without confirmation")
+	fs.Parse(args)
+
+	var delegations []pchomereg.Delegation
+	var err error
+	switch {
+	case len(*dnscontrol) > 0 && len(*octodns) > 0:
+		return errors.New("Use either -dnscontrol or -octodns.")
+	case *dnscontrol == "-":
+		delegations, err = pchomereg.ParseDNSControl(os.Stdin, *name)
+	case len(*dnscontrol) > 0:
+		f, openErr := os.Open(*dnscontrol)
+		if openErr != nil {
+			return openErr
+		}
+		delegations, err = pchomereg.ParseDNSControl(f, *name)
+		f.Close()
+	case len(*octodns) > 0:
+		delegations, err = pchomereg.ReadOctoDNSDir(*octodns)
+	default:
+		fs.Usage()
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	selected := make(map[string]bool)
+	if len(*zones) > 0 {
+		for _, zone := range splitZones(*zones) {
+			selected[zone] = true
+		}
+	}
+
+	r := pchomereg.NewRegistrar(newConfigService())
+	r.Service = service
+	r.Lock = withLock
+	names := make([]string, 0)
+	corrections := make([]*pchomereg.Correction, 0)
+	for _, d := range delegations {
+		if len(selected) > 0 && !selected[d.Zone] {
+			continue
+		}
+		fixes, err := r.Corrections(d)
+		if err != nil {
+			return errors.New(d.Zone + ": " + err.Error())
+		}
+		if len(fixes) == 0 {
+			continue
+		}
+		names = append(names, d.Zone)
+		for _, c := range fixes {
+			fmt.Printf("%s\t%s\n", c.Zone, c.Msg)
+		}
+		corrections = append(corrections, fixes...)
+	}
+	if len(corrections) == 0 {
+		fmt.Println("Nothing to change.")
+		return nil
+	}
+	if err := confirm(names, *yes); err != nil {
+		return err
+	}
+
+	for _, c := range corrections {
+		if err := c.F(); err != nil {
+			return errors.New(c.Zone + ": " + err.Error())
+		}
+	}
+
+	return nil
+}
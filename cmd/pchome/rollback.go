@@ -0,0 +1,74 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// rollback 指令，把網站上的記錄還原成快照的內容。
+func rollback(args []string) error {
+	fs := flag.NewFlagSet("rollback", flag.ExitOnError)
+	list := fs.Bool("list", false, "list snapshots")
+	to := fs.String("to", "", "snapshot id to restore")
+	zones := fs.String("zones", "", "comma separated zone names to restore, default the zones changed since the snapshot")
+	yes := fs.Bool("yes", false, "restore without confirmation")
+	fs.Parse(args)
+
+	cs := newConfigService()
+	switch {
+	case *list:
+		snapshots, err := cs.Snapshots()
+		if err != nil {
+			return err
+		}
+		for _, snapshot := range snapshots {
+			fmt.Printf("%s\t%s\t%s\t%s\n", snapshot.ID, snapshot.Time.Local().Format(time.RFC3339), snapshot.Operation, snapshot.Zone)
+		}
+		return nil
+	case len(*to) == 0:
+		fs.Usage()
+		return nil
+	}
+
+	snapshot, err := cs.ReadSnapshot(*to)
+	if err != nil {
+		return err
+	}
+	s, err := service()
+	if err != nil {
+		return err
+	}
+
+	var names []string
+	if len(*zones) > 0 {
+		names = splitZones(*zones)
+	}
+	diffs, err := cs.SnapshotDiff(s, snapshot, names)
+	if err != nil {
+		return err
+	}
+	if len(diffs) == 0 {
+		fmt.Println("Nothing to restore.")
+		return nil
+	}
+
+	names = make([]string, 0, len(diffs))
+	for name := range diffs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Println(name)
+		for _, change := range diffs[name] {
+			fmt.Printf("\t%s\n", change.String())
+		}
+	}
+	if err := confirm(names, *yes); err != nil {
+		return err
+	}
+
+	return withLock(func() error { return cs.Restore(s, snapshot, diffs) })
+}
+
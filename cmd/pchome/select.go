@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/a2n/pchome"
+)
+
+// zone 選取參數。
+type selection struct {
+	zone *string
+	match *string
+	glob *string
+	tld *string
+	yes *bool
+}
+
+// 在指令參數加上 zone 選取參數。
+func addSelection(fs *flag.FlagSet) *selection {
+	return &selection {
+		zone: fs.String("zone", "", "zone name"),
+		match: fs.String("match", "", "regex of zone names, e.g. '.*tw'"),
+		glob: fs.String("glob", "", "glob of zone names, e.g. '*.com.tw'"),
+		tld: fs.String("tld", "", "TLD of zone names, e.g. tw"),
+		yes: fs.Bool("yes", false, "do not ask for confirmation"),
+	}
+}
+
+// 是否用 regex、glob 或 TLD 選取多個 zone。
+func (sel *selection) multiple() bool {
+	return len(*sel.match) > 0 || len(*sel.glob) > 0 || len(*sel.tld) > 0
+}
+
+// 取得選取的 zone。
+func (sel *selection) zones(s *pchome.Service) ([]string, error) {
+	if !sel.multiple() {
+		return []string{*sel.zone}, nil
+	}
+
+	call := s.NewZoneService().Select()
+	if len(*sel.zone) > 0 {
+		call.Name(*sel.zone)
+	}
+	if len(*sel.match) > 0 {
+		call.Regexp(*sel.match)
+	}
+	if len(*sel.glob) > 0 {
+		call.Glob(*sel.glob)
+	}
+	if len(*sel.tld) > 0 {
+		call.TLD(*sel.tld)
+	}
+
+	names, err := call.Do()
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		return nil, errors.New("No matched zone.")
+	}
+
+	return names, nil
+}
+
+// 列出要修改的 zone 並詢問是否繼續。
+func (sel *selection) confirm(names []string) error {
+	if !sel.multiple() {
+		return nil
+	}
+
+	return confirm(names, *sel.yes)
+}
+
+// 解析以逗號分隔的 zone 名稱，去掉空白和結尾的點並轉成小寫，忽略空的名稱。
+func splitZones(s string) []string {
+	names := make([]string, 0)
+	for _, name := range strings.Split(s, ",") {
+		name = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(name), "."))
+		if len(name) > 0 {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+// 列出 zone 並詢問是否繼續，yes 為 true 時不詢問。
+func confirm(names []string, yes bool) error {
+	if yes {
+		return nil
+	}
+
+	fmt.Printf("The following %d zones will be changed:\n", len(names))
+	for _, name := range names {
+		fmt.Printf("  %s\n", name)
+	}
+	fmt.Print("Continue? [y/N] ")
+
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	if answer != "y" && answer != "yes" {
+		return errors.New("Canceled.")
+	}
+
+	return nil
+}
+
+// 對每個 zone 執行操作，失敗的 zone 不中斷其他 zone。
+func each(names []string, fn func(zone string) error) error {
+	failed := 0
+	for _, name := range names {
+		if err := fn(name); err != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "%s: %s\n", name, err.Error())
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d zones failed.", failed, len(names))
+	}
+
+	return nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/a2n/pchome/api"
+)
+
+// serve 指令，提供需要 token 的 JSON API，收到 SIGINT 或 SIGTERM 時結束。
+func serve(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", "127.0.0.1:8053", "listen address")
+	tokenPath := fs.String("tokens", "", "file of API tokens, one per line; PCHOME_API_TOKENS takes comma separated tokens")
+	fs.Parse(args)
+
+	tokens := make([]string, 0)
+	if len(*tokenPath) > 0 {
+		t, err := api.ReadTokens(*tokenPath)
+		if err != nil {
+			return err
+		}
+		tokens = append(tokens, t...)
+	}
+	for _, token := range strings.Split(os.Getenv("PCHOME_API_TOKENS"), ",") {
+		if token = strings.TrimSpace(token); len(token) > 0 {
+			tokens = append(tokens, token)
+		}
+	}
+	if len(tokens) == 0 {
+		return errors.New("No API token, set -tokens or PCHOME_API_TOKENS.")
+	}
+
+	s := api.NewServer(newConfigService(), tokens)
+	s.Service = service
+	s.Lock = withLock
+	s.Logger = logger
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	server := &http.Server {
+		Addr: *addr,
+		Handler: s,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 30 * time.Second)
+		defer cancel()
+		server.Shutdown(shutdown)
+	}()
+
+	logger.Info("api serving", "addr", *addr)
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+
+	return nil
+}
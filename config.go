@@ -8,13 +8,16 @@ import (
 	"fmt"
 	"encoding/json"
 	"os"
+	"strings"
 	"io/ioutil"
+	"path/filepath"
 	"sort"
 	"net/url"
 	"net/http"
+	"regexp"
 	"errors"
-
-	"github.com/a2n/alu"
+	"sync"
+	"log/slog"
 )
 
 // 預設的組態檔案位置
@@ -23,14 +26,72 @@ const DefaultConfigPath = ".pchome"
 // 組態服務結構
 type ConfigService struct {
 	Service *Service
+	// 登入、登出使用的 HTTP client，也會傳給建立的 Service，nil 時使用 http.DefaultClient。
+	Client *http.Client
+	// 建立的 Service 共用的限速器，nil 時每個 Service 使用自己的預設限速器。
+	Limiter *RateLimiter
+	// 同步 zone 的選項。
+	Sync SyncOptions
+	// 結構化記錄器，也會傳給建立的 Service，nil 時使用 slog.Default 並遮蔽秘密。
+	Logger *slog.Logger
+	// 觀察者，也會傳給建立的 Service，nil 時不記錄指標。
+	Observer Observer
+	// 通知者，也會傳給建立的 Service，nil 時不通知。
+	Notifier Notifier
+	// Update 持有組態鎖寫入組態，只包住讀取和儲存，不包住對網站的請求。nil 時直接執行。
+	Lock func(fn func() error) error
 }
 
 // 取得組態服務。
 func NewConfigService() *ConfigService {
-	logger = alu.NewLogger("log")
 	return &ConfigService{}
 }
 
+// 取得 HTTP client。
+func (cs *ConfigService) client() *http.Client {
+	if cs.Client != nil {
+		return cs.Client
+	}
+
+	return http.DefaultClient
+}
+
+// 取得使用組態服務 HTTP client 的服務。
+func (cs *ConfigService) newService(key string) *Service {
+	s := NewService(key)
+	s.Client = cs.Client
+	if cs.Logger != nil {
+		s.Logger = cs.Logger
+	}
+	s.Observer = cs.Observer
+	s.Notifier = cs.Notifier
+	if cs.Limiter != nil {
+		s.Limiter = cs.Limiter
+	}
+	return s
+}
+
+// 取得設定和服務相同的組態服務，NS 和 DNSSEC 異動時以此讀寫本地組態。
+func (s *Service) configService() *ConfigService {
+	cs := NewConfigService()
+	cs.Client = s.Client
+	cs.Limiter = s.Limiter
+	cs.Logger = s.Logger
+	cs.Observer = s.Observer
+	cs.Notifier = s.Notifier
+	return cs
+}
+
+// 取得已登入、設定和組態服務相同的服務，session 過期時重新登入。
+func (cs *ConfigService) NewService() (*Service, error) {
+	key, err := cs.GetKey()
+	if err != nil {
+		return nil, err
+	}
+
+	return cs.newService(key), nil
+}
+
 // 初始組態服務
 func (cs *ConfigService) Init() error {
 	b, err := ioutil.ReadFile(DefaultConfigPath)
@@ -40,14 +101,14 @@ func (cs *ConfigService) Init() error {
 				return err
 			}
 		} else {
-			logger.Printf("%s read config file failed, %s.", alu.Caller(), err.Error())
+			cs.log().Error("read config file failed", LogError, err)
 		}
 	}
 
 	var config Config
 	err = json.Unmarshal(b, &config)
 	if err != nil {
-		logger.Printf("%s unmarshal json failed, %s.", alu.Caller(), err.Error())
+		cs.log().Error("unmarshal json failed", LogError, err)
 	}
 
 	return nil
@@ -64,57 +125,63 @@ func (cs *ConfigService) initNew() error {
 	fmt.Print("Paste your email here: ")
 	_, err := fmt.Scanln(&config.Email)
 	if err != nil {
-		logger.Printf("%s scan email string failed, %s.", alu.Caller(), err.Error())
+		cs.log().Error("scan email string failed", LogError, err)
 		return errors.New("Scan email string failed.")
 	}
 	if len(config.Email) == 0 {
-		logger.Printf("%s has empty email.", alu.Caller())
+		cs.log().Error("has empty email")
 		return errors.New("Empty email.")
 	}
 
 	fmt.Print("Paste your password here: ")
 	_, err = fmt.Scanln(&config.Password)
 	if err != nil {
-		logger.Printf("%s scan password string failed, %s.", alu.Caller(), err.Error())
+		cs.log().Error("scan password string failed", LogError, err)
 		return errors.New("Scan password string failed.")
 	}
 	if len(config.Password) == 0 {
-		logger.Printf("%s has empty password.", alu.Caller())
+		cs.log().Error("has empty password")
 		return errors.New("Empty password.")
 	}
 
-	key, err := cs.DoGetKey(config.Email, config.Password)
+	key, err := cs.login(config.Email, config.Password)
 	if err != nil {
 		return err
 	}
 
-	if len(key) == 0 {
-		logger.Printf("%s gets a empty key.", alu.Caller())
-		return errors.New("Your email or password is wrong.")
-	}
-
 	// Zones & Records
-	zones, err := cs.UpdateZones(NewService(key))
-	if err != nil {
+	s := cs.newService(key)
+	zones, err := cs.UpdateZones(s)
+	if _, ok := err.(*SyncError); err != nil && !ok {
+		return err
+	}
+	config.Zones = zones
+	if name := s.PageCharset(); len(name) > 0 {
+		config.Charset = name
+	}
+	if err := cs.Save(&config); err != nil {
 		return err
-	} else {
-		config.Zones = zones
-		if err = cs.Save(&config); err != nil {
-			return err
-		}
 	}
 
-	return nil
+	return err
 }
 
-// 取得 PCHome 存取鑰匙
+// 取得 PCHome 存取鑰匙，優先使用仍然有效的 session 快取，過期才重新登入。
 func (cs *ConfigService) GetKey() (string, error) {
+	session, err := cs.ReadSession()
+	if err != nil {
+		return "", err
+	}
+	if cs.Alive(session.Key) {
+		return session.Key, nil
+	}
+
 	config, err := cs.Read()
 	if err != nil {
 		return "", err
 	}
 
-	key, err := cs.DoGetKey(config.Email, config.Password)
+	key, err := cs.login(config.Email, config.Password)
 	if err != nil {
 		return "", err
 	}
@@ -125,12 +192,12 @@ func (cs *ConfigService) GetKey() (string, error) {
 // 從網站取得 PCHome 存取鑰匙
 func (cs *ConfigService) DoGetKey(email, password string) (string, error) {
 	if len(email) == 0 {
-		logger.Printf("%s has empty email.", alu.Caller())
+		cs.log().Error("has empty email")
 		return "", errors.New("Empty email.")
 	}
 
 	if len(password) == 0 {
-		logger.Printf("%s has empty password.", alu.Caller())
+		cs.log().Error("has empty password")
 		return "", errors.New("Empty password.")
 	}
 
@@ -142,9 +209,16 @@ func (cs *ConfigService) DoGetKey(email, password string) (string, error) {
 		"ltype": []string{"checklogin"},
 	}
 
-	resp, err := http.DefaultClient.PostForm(urlstr, data)
+	req, err := http.NewRequest("POST", urlstr, strings.NewReader(data.Encode()))
+	if err != nil {
+		cs.log().Error("creates http request failed", LogError, err)
+		return "", errors.New("Creating http request failed.")
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := cs.newService("").Do(req)
 	if err != nil {
-		logger.Printf("%s http requesting failed, %s.", alu.Caller(), err.Error())
+		cs.log().Error("http requesting failed", LogError, err)
 		return "", errors.New("Http requesting failed.")
 	}
 
@@ -163,145 +237,314 @@ func (cs *ConfigService) DoGetKey(email, password string) (string, error) {
 
 // 更新組態內容。
 func (cs *ConfigService) Update() error {
+	_, err := cs.UpdateChanges()
+	return err
+}
+
+// 更新組態內容，回傳網站上和本地組態不同的 zone 及其變更，變更以本地組態為前、網站為後。
+// 網站上已移除的 zone 也會列出。同步期間被其他異動改變的 zone 保留新的內容，不算變更。
+func (cs *ConfigService) UpdateChanges() (map[string][]Change, error) {
 	// Open
 	config, err := cs.Read()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	config.UpdatedAt = time.Now().Unix()
 
 	// Zones & Records
-	key, err := cs.DoGetKey(config.Email, config.Password)
+	key, err := cs.GetKey()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	zones, err := cs.UpdateZones(NewService(key))
+	// 未過期和同步失敗的 zone 保留原本的內容。
+	s := cs.newService(key)
+	names, err := cs.zoneNames(s)
 	if err != nil {
-		return err
-	} else {
-		config.Zones = zones
-		if err = cs.Save(&config); err != nil {
+		return nil, err
+	}
+	zones, err := cs.refreshZones(s, config.Zones, names)
+	if _, ok := err.(*SyncError); err != nil && !ok {
+		return nil, err
+	}
+	syncErr := err
+
+	var before map[string]Zone
+	err = cs.lock(func() error {
+		current, err := cs.Read()
+		if err != nil {
 			return err
 		}
+		for name := range zones {
+			old, had := config.Zones[name]
+			zone, has := current.Zones[name]
+			if has && (!had || len(DiffZone(old, zone)) > 0) {
+				zones[name] = zone
+			}
+		}
+
+		before = current.Zones
+		current.Zones = zones
+		current.UpdatedAt = time.Now().Unix()
+		if name := s.PageCharset(); len(name) > 0 {
+			current.Charset = name
+		}
+		return cs.Save(&current)
+	})
+	if err != nil {
+		return nil, err
 	}
+	cs.notifyUpdate(before, zones)
 
-	return nil
+	changes := make(map[string][]Change)
+	for name, zone := range zones {
+		if diff := DiffZone(before[name], zone); len(diff) > 0 {
+			changes[name] = diff
+		}
+	}
+	for name, zone := range before {
+		if _, ok := zones[name]; !ok {
+			changes[name] = DiffZone(zone, Zone{})
+		}
+	}
+
+	return changes, syncErr
+}
+
+// 持有組態鎖執行 fn。
+func (cs *ConfigService) lock(fn func() error) error {
+	if cs.Lock == nil {
+		return fn()
+	}
+
+	return cs.Lock(fn)
 }
 
 // 更新 zone 內容。
+// 部分 zone 失敗時回傳成功的部分和 *SyncError。
 func (cs *ConfigService) UpdateZones(s *Service) (map[string]Zone, error) {
-	zones := s.NewZoneService().List().Do()
+	names, err := cs.zoneNames(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return cs.syncZones(s, names, cs.syncOptions())
+}
+
+// 只更新指定的 zone，合併進現有組態並儲存，回傳合併後的 zone。
+func (cs *ConfigService) UpdateZonesByNames(s *Service, names []string) (map[string]Zone, error) {
+	return cs.mergeZones(s, names)
+}
+
+// 只更新整個名稱符合 regex 的 zone，合併進現有組態並儲存，回傳合併後的 zone。
+// regex 會加上 ^ 和 $，example.com 不會符合 myexample.com.tw，和 ZoneSelectCall.Regexp 相同。
+func (cs *ConfigService) UpdateZonesByRegexp(s *Service, re *regexp.Regexp) (map[string]Zone, error) {
+	all, err := cs.zoneNames(s)
+	if err != nil {
+		return nil, err
+	}
+	re, err = regexp.Compile("^(?:" + re.String() + ")$")
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0)
+	for _, name := range all {
+		if re.MatchString(name) {
+			names = append(names, name)
+		}
+	}
+
+	return cs.mergeZones(s, names)
+}
+
+// 取得 PChome 網站上所有 zone 名稱。
+func (cs *ConfigService) zoneNames(s *Service) ([]string, error) {
+	zones, err := s.NewZoneService().List().Do()
+	if err != nil {
+		return nil, err
+	}
+
 	keys := make([]string, 0)
 	for k, _ := range zones {
 		keys = append(keys, k)
 	}
 	sort.Strings(keys)
 
-	ns := s.NewNSService()
-	ds := s.NewDNSSECService()
-	zone := make(map[string]Zone)
-	for k, v := range keys {
-		logger.Printf("%s received %s dns records. %d/%d) ", alu.Caller(), v, k + 1, len(zones))
-		fmt.Printf("%d/%d) Received %s dns records.\n", k + 1, len(zones), v)
+	return keys, nil
+}
 
-		nsSlice, err := ns.List(v)
-		if err != nil {
-			return nil, err
+// 更新指定的 zone 並合併進現有組態。
+func (cs *ConfigService) mergeZones(s *Service, names []string) (map[string]Zone, error) {
+	config, err := cs.Read()
+	if err != nil {
+		return nil, err
+	}
+	if config.Zones == nil {
+		config.Zones = make(map[string]Zone)
+	}
+
+	zones, err := cs.refreshZones(s, config.Zones, names)
+	if _, ok := err.(*SyncError); err != nil && !ok {
+		return nil, err
+	}
+	before := make(map[string]Zone, len(zones))
+	for name, zone := range zones {
+		if old, ok := config.Zones[name]; ok {
+			before[name] = old
 		}
+		config.Zones[name] = zone
+	}
+	if name := s.PageCharset(); len(name) > 0 {
+		config.Charset = name
+	}
+	if err := cs.Save(&config); err != nil {
+		return nil, err
+	}
+	cs.notifyUpdate(before, zones)
 
-		dnssecSlice, err := ds.List(v)
-		if err != nil {
-			return nil, err
+	return config.Zones, err
+}
+
+// 同步指定的 zone，在 TTL 內更新過的 zone 會略過。
+// 回傳的 zone 包含所有指定的名稱，略過和同步失敗的 zone 沿用 current 的內容。
+func (cs *ConfigService) refreshZones(s *Service, current map[string]Zone, names []string) (map[string]Zone, error) {
+	opt := cs.syncOptions()
+	zones := make(map[string]Zone)
+	stale := make([]string, 0)
+	for _, name := range names {
+		zone, ok := current[name]
+		if ok && opt.TTL > 0 && time.Since(time.Unix(zone.UpdatedAt, 0)) < opt.TTL {
+			zones[name] = zone
+			continue
 		}
+		stale = append(stale, name)
+	}
 
-		zone[v] = Zone {
-			NS: nsSlice,
-			DNSSEC: dnssecSlice,
+	synced, err := cs.syncZones(s, stale, opt)
+	if syncErr, ok := err.(*SyncError); ok {
+		for name := range syncErr.Errors {
+			if zone, ok := current[name]; ok {
+				zones[name] = zone
+			}
 		}
+	} else if err != nil {
+		return nil, err
+	}
+	for name, zone := range synced {
+		zones[name] = zone
 	}
 
-	return zone, nil
+	return zones, err
 }
 
 // 讀取本地組態。
 func (cs *ConfigService) Read() (Config, error) {
 	b, err := ioutil.ReadFile(DefaultConfigPath)
 	if err != nil {
-		logger.Printf("%s read configuration file failed, %s.", alu.Caller(), err.Error())
+		cs.log().Error("read configuration file failed", LogError, err)
 		return Config{}, errors.New("Read configuration file failed.")
 	}
 
 	var config Config
 	err = json.Unmarshal(b, &config)
 	if err != nil {
-		logger.Printf("%s unmarshal json failed, %s.", alu.Caller(), err.Error())
+		cs.log().Error("unmarshal json failed", LogError, err)
 		return config, errors.New("Unmarshal configuration json failed.")
 	}
 
 	return config, nil
 }
 
+// 組態檔案的讀寫鎖，避免同時修改不同 zone 時互相覆蓋。
+var configMu sync.Mutex
+
+// 儲存單一 zone 到本地組態，其他 zone 沿用組態檔案的內容。
+func (cs *ConfigService) SaveZone(name string, zone Zone) error {
+	configMu.Lock()
+	defer configMu.Unlock()
+
+	config, err := cs.Read()
+	if err != nil {
+		return err
+	}
+	if config.Zones == nil {
+		config.Zones = make(map[string]Zone)
+	}
+	config.Zones[name] = zone
+
+	return cs.Save(&config)
+}
+
 // 移除組態檔案。
 func (cs *ConfigService) Remove() error {
 	err := os.Remove(DefaultConfigPath)
 	if err != nil {
-		logger.Printf("%s remove the configuration file failed, %s.", alu.Caller(), err.Error())
+		cs.log().Error("remove the configuration file failed", LogError, err)
 		return errors.New("Failed to remove the configuration file.")
 	}
 
-	logger.Printf("%s remove the configuration file successfully", alu.Caller())
+	cs.log().Info("removed the configuration file")
 	return nil
 }
 
 // 儲存組態內容。
 func (cs *ConfigService) Save(config *Config) error {
 	if config == nil {
-		logger.Printf("%s has nil config.", alu.Caller())
+		cs.log().Error("has nil config")
 		return errors.New("nil config.")
 	}
 
 	// Write
 	b, err := json.MarshalIndent(config, "", " ")
 	if err != nil {
-		logger.Printf("%s marshal json failed, %s.", alu.Caller(), err.Error())
+		cs.log().Error("marshal json failed", LogError, err)
 		return errors.New("Marshal json failed.")
 	}
 
-	file, err := os.OpenFile(DefaultConfigPath, os.O_RDWR, os.ModePerm)
+	// 先寫入同一個目錄的暫存檔再改名，其他 goroutine 或行程不會讀到寫到一半的組態。
+	mode := os.FileMode(0600)
+	if info, err := os.Stat(DefaultConfigPath); err == nil {
+		mode = info.Mode().Perm()
+	}
+	file, err := ioutil.TempFile(filepath.Dir(DefaultConfigPath), filepath.Base(DefaultConfigPath) + ".*")
 	if err != nil {
-		if os.IsNotExist(err) {
-			file, err = os.Create(DefaultConfigPath)
-			if err != nil {
-				logger.Printf("%s creates configuration file failed, %s.", alu.Caller(), err.Error())
-				return errors.New("Create configuration file failed.")
-			} else {
-				file, err = os.OpenFile(DefaultConfigPath, os.O_RDWR, os.ModePerm)
-				if err != nil {
-					logger.Printf("%s opens configuration file failed, %s.", alu.Caller(), err.Error())
-					return errors.New("Open configuration file failed.")
-				}
-			}
-		} else {
-			logger.Printf("%s opens configuration file failed, %s.", alu.Caller(), err.Error())
-			return errors.New("Open configuration file failed.")
-		}
+		cs.log().Error("creates configuration file failed", LogError, err)
+		return errors.New("Create configuration file failed.")
+	}
+	if _, err = file.Write(b); err == nil {
+		err = file.Chmod(mode)
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
 	}
-
-	_, err = file.Write(b)
 	if err != nil {
-		file.Close()
-		logger.Printf("%s write configuration file failed, %s.", alu.Caller(), err.Error())
+		os.Remove(file.Name())
+		cs.log().Error("write configuration file failed", LogError, err)
+		return errors.New("Writing configuration file failed.")
+	}
+	if err := os.Rename(file.Name(), DefaultConfigPath); err != nil {
+		os.Remove(file.Name())
+		cs.log().Error("write configuration file failed", LogError, err)
 		return errors.New("Writing configuration file failed.")
 	}
 
-	logger.Printf("%s write configuration file successfully.", alu.Caller())
-	file.Close()
+	cs.log().Info("wrote the configuration file")
 	return nil
 }
 
-// 登出 PChome 網站。
+// 登出 PChome 網站，並移除 session 快取。
 func (cs *ConfigService) Logout() error {
+	if cs.Service == nil {
+		session, err := cs.ReadSession()
+		if err != nil {
+			return err
+		}
+		if len(session.Key) == 0 {
+			return errors.New("Empty access token.")
+		}
+		cs.Service = cs.newService(session.Key)
+	}
+
 	if len(cs.Service.Key) == 0 {
 		return errors.New("Empty access token.")
 	}
@@ -309,17 +552,19 @@ func (cs *ConfigService) Logout() error {
 	urlstr := "https://login.pchome.com.tw/adm/logout.php"
 	req, err := http.NewRequest("GET", urlstr, nil)
 	if err != nil {
-		logger.Printf("%s creates http request failed, %s.", alu.Caller(), err.Error())
+		cs.log().Error("creates http request failed", LogError, err)
 		return errors.New("Cannot create a http request.")
 	}
 	cs.Service.SetCookie(req)
 
-	_, err = http.DefaultClient.Do(req)
+	resp, err := cs.Service.Do(req)
 	if err != nil {
-		logger.Printf("%s requesting failed, %s.", alu.Caller(), err.Error())
+		cs.log().Error("requesting failed", LogError, err)
 		return errors.New("Cannot create a http request.")
 	}
-	return nil
+	resp.Body.Close()
+
+	return cs.RemoveSession()
 }
 
 // 組態結構，記錄 Email、密碼、Zones 和最後更新時間。
@@ -328,4 +573,6 @@ type Config struct {
 	Password string
 	Zones map[string]Zone
 	UpdatedAt int64
+	// PChome 網頁的編碼，提交表單時使用。
+	Charset string `json:",omitempty"`
 }
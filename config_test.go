@@ -1,6 +1,11 @@
 package pchome
 
-import "testing"
+import (
+	"net/http"
+	"os"
+	"sync"
+	"testing"
+)
 
 const (
 	Email = ""
@@ -28,3 +33,68 @@ func TestUpdateZones(t *testing.T) {
 		t.Log("Updating zones passed.")
 	}
 }
+
+func TestSaveAtomic(t *testing.T) {
+	dir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Chdir(dir)
+
+	cs := NewConfigService()
+	if err := cs.Save(&Config{Zones: map[string]Zone{}}); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// 並行寫入時讀取的一定是完整的組態。
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			cs.SaveZone("example.com", Zone{NS: NS{"ns1.example.net": ""}, UpdatedAt: int64(i)})
+		}
+		close(done)
+	}()
+	for reading := true; reading; {
+		select {
+		case <-done:
+			reading = false
+		default:
+		}
+		if _, err := cs.Read(); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	wg.Wait()
+
+	info, err := os.Stat(DefaultConfigPath)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Configuration mode is %v.", info.Mode().Perm())
+	}
+	if entries, _ := os.ReadDir("."); len(entries) != 1 {
+		t.Errorf("Temporary files are left, %v.", entries)
+	}
+}
+
+func TestServiceConfigService(t *testing.T) {
+	s := NewService("key")
+	s.Client = &http.Client{}
+	s.Limiter = NewRateLimiter(1, 1)
+	s.Notifier = &recordNotifier{}
+
+	cs := s.configService()
+	if cs.Client != s.Client || cs.Limiter != s.Limiter || cs.Logger != s.Logger || cs.Notifier != s.Notifier {
+		t.Errorf("Config service %+v does not match the service.", cs)
+	}
+	if ns := cs.newService("key"); ns.Client != s.Client || ns.Limiter != s.Limiter {
+		t.Error("Services from the config service do not share the client and limiter.")
+	}
+}
@@ -0,0 +1,121 @@
+package pchome
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 記錄差異的動作。
+const (
+	ChangeAdd = "add"
+	ChangeDelete = "delete"
+	ChangeUpdate = "update"
+)
+
+// 一筆記錄差異。
+type Change struct {
+	// ns 或 dnssec。
+	Kind string
+	// ChangeAdd、ChangeDelete 或 ChangeUpdate。
+	Action string
+	// NS 的主機名稱或 DNSSEC 記錄。
+	Name string
+	// 更新前後的 IP，只用在 NS。
+	Before string `json:",omitempty"`
+	After string `json:",omitempty"`
+}
+
+func (c Change) String() string {
+	switch c.Action {
+	case ChangeAdd:
+		if len(c.After) > 0 {
+			return "+" + c.Kind + " " + c.Name + " " + c.After
+		}
+		return "+" + c.Kind + " " + c.Name
+	case ChangeDelete:
+		if len(c.Before) > 0 {
+			return "-" + c.Kind + " " + c.Name + " " + c.Before
+		}
+		return "-" + c.Kind + " " + c.Name
+	}
+
+	return "~" + c.Kind + " " + c.Name + " " + c.Before + " -> " + c.After
+}
+
+// DNSSEC 記錄的文字表示，依序為 key tag、algorithm 和 digest。
+func (d DNSSEC) String() string {
+	return strconv.Itoa(int(d.KeyTag)) + " " + strconv.Itoa(int(d.Algorithm)) + " " + d.Digest
+}
+
+// 比較 zone 的兩個版本，回傳依種類和名稱排序的差異。
+// 主機名稱不分大小寫、不含結尾的點，IP 以標準格式、digest 以大寫比較。
+func DiffZone(before, after Zone) []Change {
+	changes := make([]Change, 0)
+	before, after = normalizeZone(before), normalizeZone(after)
+
+	for name, ip := range after.NS {
+		old, ok := before.NS[name]
+		switch {
+		case !ok:
+			changes = append(changes, Change{Kind: "ns", Action: ChangeAdd, Name: name, After: ip})
+		case old != ip:
+			changes = append(changes, Change{Kind: "ns", Action: ChangeUpdate, Name: name, Before: old, After: ip})
+		}
+	}
+	for name, ip := range before.NS {
+		if _, ok := after.NS[name]; !ok {
+			changes = append(changes, Change{Kind: "ns", Action: ChangeDelete, Name: name, Before: ip})
+		}
+	}
+
+	count := make(map[DNSSEC]int)
+	for _, r := range before.DNSSEC {
+		count[r]--
+	}
+	for _, r := range after.DNSSEC {
+		count[r]++
+	}
+	for r, n := range count {
+		action := ChangeAdd
+		if n < 0 {
+			action = ChangeDelete
+			n = -n
+		}
+		for ; n > 0; n-- {
+			changes = append(changes, Change{Kind: "dnssec", Action: action, Name: r.String()})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Kind != changes[j].Kind {
+			return changes[i].Kind > changes[j].Kind
+		}
+		if changes[i].Name != changes[j].Name {
+			return changes[i].Name < changes[j].Name
+		}
+		return changes[i].Action < changes[j].Action
+	})
+
+	return changes
+}
+
+// 正規化 zone 的記錄以便比較。
+func normalizeZone(zone Zone) Zone {
+	normalized := Zone{UpdatedAt: zone.UpdatedAt}
+	if zone.NS != nil {
+		normalized.NS = make(NS, len(zone.NS))
+		for name, glue := range zone.NS {
+			normalized.NS[strings.ToLower(strings.TrimSuffix(name, "."))] = normalizeGlue(glue)
+		}
+	}
+	if zone.DNSSEC != nil {
+		normalized.DNSSEC = make([]DNSSEC, 0, len(zone.DNSSEC))
+		for _, r := range zone.DNSSEC {
+			r.Digest = strings.ToUpper(r.Digest)
+			normalized.DNSSEC = append(normalized.DNSSEC, r)
+		}
+	}
+
+	return normalized
+}
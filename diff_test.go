@@ -0,0 +1,27 @@
+package pchome
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiffZoneNormalizes(t *testing.T) {
+	digest := strings.Repeat("ab", 32)
+	before := Zone {
+		NS: NS{"NS1.Example.COM.": "2001:DB8::1", "ns2.example.net": ""},
+		DNSSEC: []DNSSEC{{KeyTag: 2371, Algorithm: 13, Digest: digest}},
+	}
+	after := Zone {
+		NS: NS{"ns1.example.com": "2001:db8:0::1", "ns2.example.net": ""},
+		DNSSEC: []DNSSEC{{KeyTag: 2371, Algorithm: 13, Digest: strings.ToUpper(digest)}},
+	}
+	if changes := DiffZone(before, after); len(changes) != 0 {
+		t.Errorf("Got changes %v, want none.", changes)
+	}
+
+	after.NS["ns1.example.com"] = "2001:db8::2"
+	changes := DiffZone(before, after)
+	if len(changes) != 1 || changes[0].String() != "~ns ns1.example.com 2001:db8::1 -> 2001:db8::2" {
+		t.Errorf("Got changes %v.", changes)
+	}
+}
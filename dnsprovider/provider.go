@@ -0,0 +1,483 @@
+// Package dnsprovider 以 libdns 介面操作 PChome 域名，讓 Caddy 等 libdns 使用者直接管理記錄。
+//
+// zone 頂點的 NS 和 DS 記錄對應到註冊商層級的 NSService 和 DNSSECService，
+// 需要本地組態裡有該 zone；A、AAAA、CNAME、MX 和 TXT 對應到 PChome 代管 DNS 的 HostService，
+// zone 使用 PChome DNS 時才能修改。PChome 沒有 TTL，回傳的記錄 TTL 為 0，比對記錄時也忽略 TTL。
+// 轉址記錄不會回傳，也不會被修改。寫入不是原子操作，NS、DS 和代管記錄依序提交。
+package dnsprovider
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/a2n/pchome"
+	"github.com/libdns/libdns"
+)
+
+var (
+	_ libdns.RecordGetter = (*Provider)(nil)
+	_ libdns.RecordAppender = (*Provider)(nil)
+	_ libdns.RecordSetter = (*Provider)(nil)
+	_ libdns.RecordDeleter = (*Provider)(nil)
+	_ libdns.ZoneLister = (*Provider)(nil)
+)
+
+// libdns provider。
+type Provider struct {
+	// 取得已登入的服務，nil 時以目前目錄的組態登入。
+	Service func() (*pchome.Service, error)
+	// 持有組態鎖執行寫入，NSService 和 DNSSECService 會寫入組態。nil 時使用 pchome.WithLock。
+	Lock func(fn func() error) error
+
+	mu sync.Mutex
+}
+
+// zone 的所有記錄。
+type state struct {
+	ns pchome.NS
+	ds []pchome.DNSSEC
+	// 代管記錄，不含轉址。
+	hosts []pchome.HostRecord
+	forwards []pchome.HostRecord
+	hosted bool
+}
+
+func (p *Provider) service() (*pchome.Service, error) {
+	if p.Service != nil {
+		return p.Service()
+	}
+
+	return pchome.NewConfigService().NewService()
+}
+
+func (p *Provider) lock(fn func() error) error {
+	if p.Lock != nil {
+		return p.Lock(fn)
+	}
+
+	return pchome.WithLock(fn)
+}
+
+// 去掉結尾的點並轉成小寫。
+func zoneName(zone string) string {
+	return strings.ToLower(strings.TrimSuffix(zone, "."))
+}
+
+func (p *Provider) ListZones(ctx context.Context) ([]libdns.Zone, error) {
+	s, err := p.service()
+	if err != nil {
+		return nil, err
+	}
+	zones, err := s.NewZoneService().List().Do()
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]libdns.Zone, 0, len(zones))
+	for name := range zones {
+		list = append(list, libdns.Zone{Name: name + "."})
+	}
+
+	return list, nil
+}
+
+func (p *Provider) GetRecords(ctx context.Context, zone string) ([]libdns.Record, error) {
+	s, err := p.service()
+	if err != nil {
+		return nil, err
+	}
+	st, err := load(ctx, s, zoneName(zone))
+	if err != nil {
+		return nil, err
+	}
+
+	return typed(st.rrs()), nil
+}
+
+func (p *Provider) AppendRecords(ctx context.Context, zone string, recs []libdns.Record) ([]libdns.Record, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.change(ctx, zoneName(zone), func(rrs []libdns.RR) ([]libdns.RR, []libdns.RR, error) {
+		added := make([]libdns.RR, 0)
+		for _, rec := range recs {
+			rr, err := normalize(rec.RR())
+			if err != nil {
+				return nil, nil, err
+			}
+			if indexOf(rrs, rr) < 0 {
+				rrs = append(rrs, rr)
+				added = append(added, rr)
+			}
+		}
+		return rrs, added, nil
+	})
+}
+
+func (p *Provider) SetRecords(ctx context.Context, zone string, recs []libdns.Record) ([]libdns.Record, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.change(ctx, zoneName(zone), func(rrs []libdns.RR) ([]libdns.RR, []libdns.RR, error) {
+		input := make([]libdns.RR, 0, len(recs))
+		sets := make(map[[2]string]bool)
+		for _, rec := range recs {
+			rr, err := normalize(rec.RR())
+			if err != nil {
+				return nil, nil, err
+			}
+			input = append(input, rr)
+			sets[[2]string{rr.Name, rr.Type}] = true
+		}
+
+		next := make([]libdns.RR, 0, len(rrs) + len(input))
+		for _, rr := range rrs {
+			if !sets[[2]string{rr.Name, rr.Type}] {
+				next = append(next, rr)
+			}
+		}
+		for _, rr := range input {
+			if indexOf(next, rr) < 0 {
+				next = append(next, rr)
+			}
+		}
+		return next, input, nil
+	})
+}
+
+func (p *Provider) DeleteRecords(ctx context.Context, zone string, recs []libdns.Record) ([]libdns.Record, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.change(ctx, zoneName(zone), func(rrs []libdns.RR) ([]libdns.RR, []libdns.RR, error) {
+		next := make([]libdns.RR, 0, len(rrs))
+		deleted := make([]libdns.RR, 0)
+		for _, rr := range rrs {
+			matched := false
+			for _, rec := range recs {
+				if matches(rr, rec.RR()) {
+					matched = true
+					break
+				}
+			}
+			if matched {
+				deleted = append(deleted, rr)
+			} else {
+				next = append(next, rr)
+			}
+		}
+		return next, deleted, nil
+	})
+}
+
+// 讀取 zone 的記錄，以 fn 計算新的記錄後提交有變動的部分，回傳 fn 回報的記錄。
+func (p *Provider) change(ctx context.Context, zone string, fn func(rrs []libdns.RR) ([]libdns.RR, []libdns.RR, error)) ([]libdns.Record, error) {
+	s, err := p.service()
+	if err != nil {
+		return nil, err
+	}
+	st, err := load(ctx, s, zone)
+	if err != nil {
+		return nil, err
+	}
+
+	rrs, result, err := fn(st.rrs())
+	if err != nil {
+		return nil, err
+	}
+	next, err := st.apply(rrs)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.lock(func() error { return save(ctx, s, zone, st, next) }); err != nil {
+		return nil, err
+	}
+
+	return typed(result), nil
+}
+
+// 從 PChome 網站讀取 zone 的記錄，zone 使用自管 DNS 時沒有代管記錄。
+func load(ctx context.Context, s *pchome.Service, zone string) (*state, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	st := &state{}
+
+	var err error
+	if st.ns, err = s.NewNSService().List(zone); err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if st.ds, err = s.NewDNSSECService().List(zone); err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	records, err := s.NewHostService().List(zone)
+	if err == pchome.ErrNotHosted {
+		return st, nil
+	} else if err != nil {
+		return nil, err
+	}
+	st.hosted = true
+	for _, r := range records {
+		if r.Type == pchome.HostForward {
+			st.forwards = append(st.forwards, r)
+		} else {
+			st.hosts = append(st.hosts, r)
+		}
+	}
+
+	return st, nil
+}
+
+// 只提交和 old 不同的 NS、DS 和代管記錄。
+func save(ctx context.Context, s *pchome.Service, zone string, old, next *state) error {
+	if !reflect.DeepEqual(old.ns, next.ns) {
+		// 提交 NS 會把 zone 切換成自管 DNS 並清除代管記錄。
+		if old.hosted {
+			return errors.New("The zone uses PChome DNS, changing NS records would switch it to its own name servers.")
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := s.NewNSService().Set(zone, next.ns); err != nil {
+			return err
+		}
+	}
+
+	if !reflect.DeepEqual(old.ds, next.ds) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := s.NewDNSSECService().Set(zone, next.ds); err != nil {
+			return err
+		}
+	}
+
+	if !reflect.DeepEqual(old.hosts, next.hosts) {
+		if !old.hosted {
+			return pchome.ErrNotHosted
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		records := append(append([]pchome.HostRecord{}, next.hosts...), old.forwards...)
+		if err := s.NewHostService().Set(zone, records); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// 轉成 libdns 記錄，NS 主機名稱加上結尾的點。
+func (st *state) rrs() []libdns.RR {
+	rrs := make([]libdns.RR, 0)
+	for name := range st.ns {
+		rrs = append(rrs, libdns.RR{Name: "@", Type: "NS", Data: name + "."})
+	}
+	for _, r := range st.ds {
+		rrs = append(rrs, libdns.RR{Name: "@", Type: "DS", Data: pchome.FormatDS(r)})
+	}
+	for _, r := range st.hosts {
+		name := r.Name
+		if len(name) == 0 {
+			name = "@"
+		}
+		rrs = append(rrs, libdns.RR{Name: name, Type: r.Type, Data: r.Content})
+	}
+
+	return rrs
+}
+
+// 由 libdns 記錄產生新的狀態，NS 沿用原本的 glue IP。
+func (st *state) apply(rrs []libdns.RR) (*state, error) {
+	next := &state {
+		ns: make(pchome.NS),
+		ds: make([]pchome.DNSSEC, 0),
+		hosts: make([]pchome.HostRecord, 0),
+		hosted: st.hosted,
+	}
+
+	for _, rr := range rrs {
+		switch rr.Type {
+		case "NS":
+			name := zoneName(rr.Data)
+			next.ns[name] = st.ns[name]
+		case "DS":
+			r, err := pchome.ParseDS(rr.Data)
+			if err != nil {
+				return nil, err
+			}
+			next.ds = append(next.ds, r)
+		default:
+			name := rr.Name
+			if name == "@" {
+				name = ""
+			}
+			next.hosts = append(next.hosts, pchome.HostRecord{Name: name, Type: rr.Type, Content: rr.Data})
+		}
+	}
+
+	// 沒有變動時保留原本的順序，避免不必要的提交。
+	if sameNS(st.ns, next.ns) {
+		next.ns = st.ns
+	}
+	if sameDNSSEC(st.ds, next.ds) {
+		next.ds = st.ds
+	}
+	if sameHosts(st.hosts, next.hosts) {
+		next.hosts = st.hosts
+	}
+
+	return next, nil
+}
+
+func sameNS(a, b pchome.NS) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, ip := range a {
+		if other, ok := b[name]; !ok || other != ip {
+			return false
+		}
+	}
+
+	return true
+}
+
+func sameDNSSEC(a, b []pchome.DNSSEC) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	count := make(map[pchome.DNSSEC]int)
+	for _, r := range a {
+		count[r]++
+	}
+	for _, r := range b {
+		count[r]--
+		if count[r] < 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+func sameHosts(a, b []pchome.HostRecord) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	count := make(map[pchome.HostRecord]int)
+	for _, r := range a {
+		count[r]++
+	}
+	for _, r := range b {
+		count[r]--
+		if count[r] < 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// 檢查並正規化輸入的記錄，只接受 zone 頂點的 NS 和 DS。
+func normalize(rr libdns.RR) (libdns.RR, error) {
+	rr.TTL = 0
+	rr.Type = strings.ToUpper(rr.Type)
+	rr.Name = strings.ToLower(rr.Name)
+	if len(rr.Name) == 0 {
+		rr.Name = "@"
+	}
+
+	switch rr.Type {
+	case "NS":
+		if rr.Name != "@" {
+			return rr, errors.New("NS records are only supported at the zone apex.")
+		}
+		name := zoneName(rr.Data)
+		if len(name) == 0 {
+			return rr, errors.New("Empty NS target.")
+		}
+		rr.Data = name + "."
+	case "DS":
+		if rr.Name != "@" {
+			return rr, errors.New("DS records are only supported at the zone apex.")
+		}
+		r, err := pchome.ParseDS(rr.Data)
+		if err != nil {
+			return rr, err
+		}
+		rr.Data = pchome.FormatDS(r)
+	case pchome.HostA, pchome.HostAAAA, pchome.HostCNAME, pchome.HostMX, pchome.HostTXT:
+		if len(rr.Data) == 0 {
+			return rr, errors.New("Empty " + rr.Type + " record data.")
+		}
+	default:
+		return rr, errors.New("Unsupported record type " + rr.Type + ".")
+	}
+
+	return rr, nil
+}
+
+// 記錄的位置，比對時忽略 TTL，NS、CNAME、MX 和 DS 不分大小寫。
+func indexOf(rrs []libdns.RR, rr libdns.RR) int {
+	for i, r := range rrs {
+		if r.Name == rr.Name && r.Type == rr.Type && sameData(r.Type, r.Data, rr.Data) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// 是否符合刪除條件，Type 和 Data 為空時不比對。
+func matches(rr, query libdns.RR) bool {
+	name := strings.ToLower(query.Name)
+	if len(name) == 0 {
+		name = "@"
+	}
+	if rr.Name != name {
+		return false
+	}
+	if len(query.Type) > 0 && rr.Type != strings.ToUpper(query.Type) {
+		return false
+	}
+	if len(query.Data) > 0 && !sameData(rr.Type, rr.Data, query.Data) {
+		return false
+	}
+
+	return true
+}
+
+func sameData(typ, a, b string) bool {
+	switch typ {
+	case "NS", "CNAME", "MX", "DS":
+		return strings.EqualFold(strings.TrimSuffix(a, "."), strings.TrimSuffix(b, "."))
+	}
+
+	return a == b
+}
+
+// 轉成 libdns 定義的記錄類型，DS 沒有對應的類型時回傳 RR。
+func typed(rrs []libdns.RR) []libdns.Record {
+	records := make([]libdns.Record, 0, len(rrs))
+	for _, rr := range rrs {
+		record, err := rr.Parse()
+		if err != nil {
+			record = rr
+		}
+		records = append(records, record)
+	}
+
+	return records
+}
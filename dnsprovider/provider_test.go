@@ -0,0 +1,143 @@
+package dnsprovider
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/a2n/pchome"
+	"github.com/a2n/pchome/pchometest"
+	"github.com/libdns/libdns"
+)
+
+// 在暫存目錄建立組態，回傳連到模擬網站的 provider。
+func newTestProvider(t *testing.T) (*Provider, *pchometest.Server) {
+	srv := pchometest.NewServer()
+	t.Cleanup(srv.Close)
+
+	dir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err.Error())
+	}
+	t.Cleanup(func() { os.Chdir(dir) })
+
+	config := pchome.Config {
+		Zones: map[string]pchome.Zone{"example.com": {NS: pchome.NS{}}},
+	}
+	if err := pchome.NewConfigService().Save(&config); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	return &Provider {
+		Service: func() (*pchome.Service, error) { return srv.Service(), nil },
+	}, srv
+}
+
+func TestDelegation(t *testing.T) {
+	p, srv := newTestProvider(t)
+	srv.SetZone("example.com", pchome.Zone {
+		NS: pchome.NS {
+			"ns0.example.com": "192.0.2.1",
+			"ns1.example.com": "192.0.2.2",
+			"ns-2.example.com": "192.0.2.3",
+			"NS3.Example.COM": "192.0.2.4",
+			"ns4.example.net": "",
+		},
+		DNSSEC: []pchome.DNSSEC {
+			{KeyTag: 2371, Algorithm: 13, Digest: "4355a46b19d348dc2f57c046f8ef63d4538ebb936000f3c9ee954a27460dd865"},
+			{KeyTag: 60485, Algorithm: 8, Digest: "2BB183AF5F22588179A53B0A98631FAD1A292118"},
+			{KeyTag: 31589, Algorithm: 14, Digest: "72d7b62976ce06438e9c0bf319013cf801f09ecc84b8d7e9495f27e305c6a9b0563a9b5f4d288405c3008a946df983d6"},
+		},
+	})
+	ctx := context.Background()
+
+	records, err := p.GetRecords(ctx, "example.com.")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	ns, ds := 0, 0
+	sha1 := false
+	for _, r := range records {
+		switch r.RR().Type {
+		case "NS":
+			ns++
+		case "DS":
+			ds++
+			sha1 = sha1 || r.RR().Data == "60485 8 1 2BB183AF5F22588179A53B0A98631FAD1A292118"
+		}
+	}
+	if ns != 5 || ds != 3 || !sha1 {
+		t.Errorf("Records have %d NS and %d DS, %v.", ns, ds, records)
+	}
+
+	if _, err := p.AppendRecords(ctx, "example.com.", []libdns.Record{libdns.TXT{Name: "_acme-challenge", Text: "token"}}); err != pchome.ErrNotHosted {
+		t.Errorf("Appending TXT to a self hosted zone has error %v.", err)
+	}
+	if _, err := p.AppendRecords(ctx, "example.com.", []libdns.Record{libdns.NS{Name: "www", Target: "ns9.example.net."}}); err == nil {
+		t.Error("Appending NS below the apex succeeds.")
+	}
+	if srv.Posts() != 0 {
+		t.Fatalf("Failed calls posted %d times.", srv.Posts())
+	}
+
+	deleted, err := p.DeleteRecords(ctx, "example.com.", []libdns.Record{libdns.NS{Name: "@", Target: "NS4.example.net"}, libdns.TXT{Name: "@", Text: "none"}})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(deleted) != 1 || deleted[0].RR().Data != "ns4.example.net." {
+		t.Errorf("Deleted %v.", deleted)
+	}
+	site, _ := srv.Zone("example.com")
+	if len(site.NS) != 4 || site.NS["ns0.example.com"] != "192.0.2.1" {
+		t.Errorf("Site has NS %v.", site.NS)
+	}
+	if srv.Posts() != 1 || len(site.DNSSEC) != 3 {
+		t.Errorf("Unchanged DS records are posted, %d posts.", srv.Posts())
+	}
+}
+
+func TestHosted(t *testing.T) {
+	p, srv := newTestProvider(t)
+	srv.SetHosts("example.com", []pchome.HostRecord {
+		{Name: "", Type: pchome.HostA, Content: "192.0.2.10"},
+		{Name: "www", Type: pchome.HostCNAME, Content: "example.com"},
+		{Name: "", Type: pchome.HostMX, Content: "10 mail.example.com"},
+		{Name: "_acme-challenge", Type: pchome.HostTXT, Content: "token"},
+		{Name: "blog", Type: pchome.HostForward, Content: "http://blog.example.net/"},
+	})
+	srv.SetTitle("example.com", "blog", "Blog")
+	ctx := context.Background()
+
+	records, err := p.GetRecords(ctx, "example.com")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(records) != 4 {
+		t.Fatalf("Records %v, want 4 without the forwarding record.", records)
+	}
+	if mx, ok := records[2].(libdns.MX); !ok || mx.Preference != 10 || mx.Name != "@" {
+		t.Errorf("Third record %#v is not the MX record.", records[2])
+	}
+
+	if _, err := p.SetRecords(ctx, "example.com", []libdns.Record{libdns.NS{Name: "@", Target: "ns1.example.net."}}); err == nil {
+		t.Error("Setting NS on a PChome DNS zone succeeds.")
+	}
+
+	set, err := p.SetRecords(ctx, "example.com", []libdns.Record{libdns.TXT{Name: "_acme-challenge", Text: "new"}})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(set) != 1 {
+		t.Errorf("Set %v.", set)
+	}
+	hosts, ok := srv.Hosts("example.com")
+	if !ok || len(hosts) != 5 || hosts[3] != (pchome.HostRecord{Name: "_acme-challenge", Type: pchome.HostTXT, Content: "new"}) {
+		t.Fatalf("Site has records %v, hosted %v.", hosts, ok)
+	}
+	if hosts[4].Content != "http://blog.example.net/" || srv.Title("example.com", "blog") != "Blog" {
+		t.Errorf("Forwarding record is posted as %v with title %q.", hosts[4], srv.Title("example.com", "blog"))
+	}
+}
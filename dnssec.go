@@ -2,14 +2,12 @@ package pchome
 
 import (
 	"net/http"
-	"io/ioutil"
-	"regexp"
+	"encoding/hex"
+	"sort"
 	"strconv"
 	"strings"
 	"net/url"
 	"errors"
-
-	"github.com/a2n/alu"
 )
 
 // DNSSEC 結構，有 KeyTag、Algorithm 和 Digest。
@@ -19,6 +17,55 @@ type DNSSEC struct {
 	Digest string
 }
 
+// 依 digest 長度推測 DS 的 digest type，SHA-1 為 1、SHA-256 為 2、SHA-384 為 4，無法判斷時為 0。
+func DigestType(digest string) int {
+	switch len(digest) {
+	case 40:
+		return 1
+	case 64:
+		return 2
+	case 96:
+		return 4
+	}
+
+	return 0
+}
+
+// 解析「key tag 演算法 digest type digest」格式的 DS 記錄，digest 可以分成多段，保留原本的大小寫。
+// digest type 必須和 digest 長度相符。
+func ParseDS(data string) (DNSSEC, error) {
+	fields := strings.Fields(data)
+	if len(fields) < 4 {
+		return DNSSEC{}, errors.New("DS data should be key tag, algorithm, digest type and digest.")
+	}
+	keyTag, err := strconv.ParseUint(fields[0], 10, 16)
+	if err != nil {
+		return DNSSEC{}, errors.New("Bad DS key tag " + fields[0] + ".")
+	}
+	algorithm, err := strconv.ParseUint(fields[1], 10, 8)
+	if err != nil {
+		return DNSSEC{}, errors.New("Bad DS algorithm " + fields[1] + ".")
+	}
+	digestType, err := strconv.Atoi(fields[2])
+	if err != nil {
+		return DNSSEC{}, errors.New("Bad DS digest type " + fields[2] + ".")
+	}
+	digest := strings.Join(fields[3:], "")
+	if _, err := hex.DecodeString(digest); err != nil {
+		return DNSSEC{}, errors.New("Bad DS digest " + digest + ".")
+	}
+	if digestType != DigestType(digest) {
+		return DNSSEC{}, errors.New("DS digest type " + fields[2] + " does not match the digest length.")
+	}
+
+	return DNSSEC{KeyTag: uint16(keyTag), Algorithm: uint8(algorithm), Digest: digest}, nil
+}
+
+// 轉成「key tag 演算法 digest type digest」格式，digest type 依 digest 長度推測，和 ParseDS 相反。
+func FormatDS(r DNSSEC) string {
+	return strconv.Itoa(int(r.KeyTag)) + " " + strconv.Itoa(int(r.Algorithm)) + " " + strconv.Itoa(DigestType(r.Digest)) + " " + r.Digest
+}
+
 // DNSSEC 服務結構
 type DNSSECService struct {
 	Service *Service
@@ -29,16 +76,17 @@ type DNSSECService struct {
 
 // 添加 DNSSEC 記錄。
 func (ds *DNSSECService) Add(zone string, keyTag uint16, algorithm uint8, digest string) error {
-	ds.cs = NewConfigService()
+	ds.cs = ds.Service.configService()
 	config, err := ds.cs.Read()
 	if err != nil {
 		return err
 	}
 	ds.config = config
+	log := ds.Service.log().With(LogOperation, "dnssec.add", LogZone, zone)
 
 	// Zone
 	if _, ok := ds.config.Zones[zone]; !ok {
-		logger.Printf("%s has no such zone name, %s.", alu.Caller(), zone)
+		log.Error("no such zone name")
 		return errors.New("No such zone name")
 	}
 	zoneObj := ds.config.Zones[zone]
@@ -46,14 +94,14 @@ func (ds *DNSSECService) Add(zone string, keyTag uint16, algorithm uint8, digest
 
 	// Max records count.
 	if len(zoneObj.DNSSEC) == 5 {
-		logger.Printf("%s, the zone(%s) has reached the max DNESEC records count 5.", alu.Caller(), zone)
+		log.Error("reaching the max DNSSEC record count 5")
 		return errors.New("The DNSSEC records of this zone is reaching the max count 5, delete some records first.\n")
 	}
 
 	// Find existed records.
 	for _, dnssec := range zoneObj.DNSSEC {
 		if dnssec.KeyTag == keyTag && dnssec.Algorithm == algorithm && dnssec.Digest == digest {
-			logger.Printf("%s has duplicated.")
+			log.Error("duplicated DNSSEC record", "key_tag", keyTag)
 			return errors.New("Duplicated record.")
 		}
 	}
@@ -65,66 +113,118 @@ func (ds *DNSSECService) Add(zone string, keyTag uint16, algorithm uint8, digest
 	}
 	zoneObj.DNSSEC = append(zoneObj.DNSSEC, record)
 	ds.config.Zones[ds.zone] = zoneObj
-	ds.save()
-
-	return nil
+	return ds.save("dnssec.add", 1)
 }
 
 // 移除 DNSSEC 記錄。
 func (ds *DNSSECService) Delete(zone string, keyTag uint16, algorithm uint8, digest string) error {
-	ds.cs = NewConfigService()
+	ds.cs = ds.Service.configService()
 	config, err := ds.cs.Read()
 	if err != nil {
 		return err
 	}
 	ds.config = config
+	log := ds.Service.log().With(LogOperation, "dnssec.delete", LogZone, zone)
 
 	// Zone
 	if _, ok := ds.config.Zones[zone]; !ok {
-		logger.Printf("%s has no matched zone name, %s.", alu.Caller(), zone)
+		log.Error("no matched zone name")
 		return errors.New("No matched zone name.")
 	}
 	zoneObj := ds.config.Zones[zone]
 	ds.zone = zone
 
 	// Find existed records.
-	found := false
 	for i, dnssec := range zoneObj.DNSSEC {
 		if dnssec.KeyTag == keyTag && dnssec.Algorithm == algorithm && dnssec.Digest == digest {
-			found = true
 			zoneObj.DNSSEC = append(zoneObj.DNSSEC[:i], zoneObj.DNSSEC[i + 1:]...)
 			ds.config.Zones[zone] = zoneObj
-			ds.save()
-			break
+			return ds.save("dnssec.delete", 1)
 		}
 	}
-	if !found {
-		logger.Printf("%s has no matched DNSSEC record.", alu.Caller())
-		return errors.New("No matched DNSSEC record.")
+
+	log.Error("no matched DNSSEC record", "key_tag", keyTag)
+	return errors.New("No matched DNSSEC record.")
+}
+
+// 以 records 取代 zone 的所有 DNSSEC 記錄。
+func (ds *DNSSECService) Set(zone string, records []DNSSEC) error {
+	ds.cs = ds.Service.configService()
+	config, err := ds.cs.Read()
+	if err != nil {
+		return err
 	}
+	ds.config = config
+	log := ds.Service.log().With(LogOperation, "dnssec.set", LogZone, zone)
 
-	return nil
+	// Zone
+	zoneObj, ok := ds.config.Zones[zone]
+	if !ok {
+		log.Error("no matched zone name")
+		return errors.New("No matched zone name.")
+	}
+	ds.zone = zone
+
+	if len(records) > 5 {
+		log.Error("more than 5 DNSSEC records", "count", len(records))
+		return errors.New("A zone can have at most 5 DNSSEC records.")
+	}
+
+	changed := len(DiffZone(Zone{DNSSEC: zoneObj.DNSSEC}, Zone{DNSSEC: records}))
+	zoneObj.DNSSEC = append([]DNSSEC{}, records...)
+	ds.config.Zones[zone] = zoneObj
+
+	return ds.save("dnssec.set", changed)
 }
 
-// 提交 DNSSEC 記錄到 PChome 網站。
-func (ds *DNSSECService) save() error {
-	reader := strings.NewReader(ds.preparePostData().Encode())
+// 提交 DNSSEC 記錄到 PChome 網站，提交前先存快照，提交後寫入稽核日誌，op 為異動名稱，changed 為異動的記錄數。
+func (ds *DNSSECService) save(op string, changed int) (err error) {
+	log := ds.Service.log().With(LogOperation, op, LogZone, ds.zone)
+	snapshot, err := ds.cs.takeSnapshot(op, ds.zone)
+	if err != nil {
+		return err
+	}
+	before := ds.cs.currentZone(ds.zone)
+	status := 0
+	defer func() {
+		ds.cs.audit(op, ds.zone, snapshot, before, ds.config.Zones[ds.zone], status, err)
+	}()
+
 	urlstr := ENDPOINT + "/set_dnssec.php"
+	body, err := encodeForm(ds.preparePostData(), ds.Service.formCharset(hostOf(urlstr), ds.config.Charset))
+	if err != nil {
+		return err
+	}
+	reader := strings.NewReader(body)
 	req, err := http.NewRequest("POST", urlstr, reader)
 	if err != nil {
-		logger.Printf("%s creates http request failed, %s.", alu.Caller(), err.Error())
+		log.Error("creates http request failed", LogError, err)
 		return errors.New("Creating http request failed.")
 	}
 	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 	ds.Service.SetCookie(req)
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := ds.Service.Do(req)
 	if err != nil {
-		logger.Printf("%s requesting failed, %s.", alu.Caller(), err.Error())
+		log.Error("requesting failed", LogError, err)
 		return errors.New("Having http requesting failed.")
 	}
+	status = resp.StatusCode
+	if err := checkStatus(log, resp); err != nil {
+		return err
+	}
 	resp.Body.Close()
-	ds.cs.Save(&ds.config)
+	log.Info("saved DNSSEC records", "changed", changed)
+	ds.Service.observer().ObserveRecordsChanged(ds.zone, "dnssec", changed)
+	notify(ds.Service.Notifier, log, Event {
+		Type: EventChange,
+		Zone: ds.zone,
+		Operation: op,
+		Changes: DiffZone(before, ds.config.Zones[ds.zone]),
+	})
+	if err := ds.cs.SaveZone(ds.zone, ds.config.Zones[ds.zone]); err != nil {
+		return err
+	}
 
 	return nil
 }
@@ -152,75 +252,98 @@ func (ds *DNSSECService) preparePostData() url.Values {
 
 // 列舉 PChome 網站的 DNSSEC 記錄。
 func (ds *DNSSECService) List(zone string) ([]DNSSEC, error) {
+	log := ds.Service.log().With(LogZone, zone)
 	if len(zone) == 0 {
-		logger.Printf("%s has empty zone name.", alu.Caller())
+		log.Error("has empty zone name")
 	}
 
 	urlstr := "http://myname.pchome.com.tw/manage/set_dnssec.htm?dn=" + zone
 	req, err := http.NewRequest("GET", urlstr, nil)
 	if err != nil {
-		logger.Printf("%s creates http request failed, %s.", alu.Caller(), err.Error())
+		log.Error("creates http request failed", LogError, err)
 		return nil, errors.New("Cannot create a http request.")
 	}
 	ds.Service.SetCookie(req)
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := ds.Service.Do(req)
 	if err != nil {
-		logger.Printf("%s requesting failed, %s.", alu.Caller(), err.Error())
+		log.Error("requesting failed", LogError, err)
 		return nil, errors.New("Having http requesting failed.")
 	}
+	if err := checkStatus(log, resp); err != nil {
+		return nil, err
+	}
 
-	b, err := ioutil.ReadAll(resp.Body)
+	b, err := ds.Service.readBody(resp)
 	if err != nil {
-		logger.Printf("%s reads http body failed, %s.", alu.Caller(), err.Error())
-		return nil, errors.New("Reads http body failed.")
+		resp.Body.Close()
+		return nil, err
 	}
 	resp.Body.Close()
 
 	slice, err := ds.parse(b)
 	if err != nil {
-		return nil, err
+		return nil, ds.Service.parseFailed(err)
 	}
 	return slice, nil
 }
 
-// 解析 PChome DNSSEC 網頁。
+// 解析 PChome DNSSEC 網頁，依欄位編號配對 KeyTag、alg 和 DS。
 func (ds *DNSSECService) parse(raw []byte) ([]DNSSEC, error) {
 	if len(raw) == 0 {
-		logger.Printf("%s has empty raw.", alu.Caller())
+		ds.Service.log().Error("has empty raw")
 		return nil, errors.New("Empty content to parse.")
 	}
 
-	reKeyTag := regexp.MustCompile(`KeyTag\d" value="(\d{1,6})`)
-	keyTags := reKeyTag.FindAllStringSubmatch(string(raw), -1)
-	reAlgorithm := regexp.MustCompile(`alg\d" value="(\d{1,3})`)
-	algorithms := reAlgorithm.FindAllStringSubmatch(string(raw), -1)
-	reDigest := regexp.MustCompile(`DS\d" value="([\d|\w]+)`)
-	digests := reDigest.FindAllStringSubmatch(string(raw), -1)
+	p := parsePage(raw)
+	if p.isLogin() {
+		ds.Service.log().Error("gets the login page")
+		return nil, &ParseError{Page: "dnssec", Msg: "is the login page"}
+	}
+
+	keyTags := p.slots("KeyTag")
+	algorithms := p.slots("alg")
+	digests := p.slots("DS")
+	if len(keyTags) == 0 || len(keyTags) != len(algorithms) || len(algorithms) != len(digests) {
+		ds.Service.log().Warn("mismatched DNSSEC fields", "key_tags", len(keyTags), "algorithms", len(algorithms), "digests", len(digests))
+		return nil, &ParseError{Page: "dnssec", Msg: "has mismatched KeyTag, alg and DS fields"}
+	}
 
-	if len(keyTags) != len(algorithms) && len(algorithms) != len(digests) {
-		logger.Printf("%s has difference results.", alu.Caller())
-		return nil, errors.New("DNSSEC data does not match regex patterns.")
+	idxs := make([]int, 0, len(keyTags))
+	for idx := range keyTags {
+		idxs = append(idxs, idx)
 	}
+	sort.Ints(idxs)
 
 	records := make([]DNSSEC, 0)
-	for i := 0; i < len(keyTags); i++ {
-		keyTag, err := strconv.Atoi(keyTags[i][1])
+	for _, idx := range idxs {
+		n := strconv.Itoa(idx)
+		keyTagStr, algorithmStr, digest := keyTags[idx], algorithms[idx], digests[idx]
+		if len(keyTagStr) == 0 && len(digest) == 0 {
+			continue
+		}
+
+		keyTag, err := strconv.ParseUint(keyTagStr, 10, 16)
 		if err != nil {
-			logger.Printf("%s parse string(%s) failed, %s.", alu.Caller(), keyTags[i][1], err.Error())
-			return nil, errors.New("Parse key tag failed.")
+			ds.Service.log().Warn("bad key tag", "field", "KeyTag" + n, "value", keyTagStr, LogError, err)
+			return nil, &ParseError{Page: "dnssec", Field: "KeyTag" + n, Msg: "is not a key tag"}
 		}
 
-		algorithm, err := strconv.Atoi(algorithms[i][1])
+		algorithm, err := strconv.ParseUint(algorithmStr, 10, 8)
 		if err != nil {
-			logger.Printf("%s parse string(%s) failed, %s.", alu.Caller(), algorithms[i][1], err.Error())
-			return nil, errors.New("Parse algorithm failed.")
+			ds.Service.log().Warn("bad algorithm", "field", "alg" + n, "value", algorithmStr, LogError, err)
+			return nil, &ParseError{Page: "dnssec", Field: "alg" + n, Msg: "is not an algorithm"}
+		}
+
+		if _, err := hex.DecodeString(digest); err != nil || len(digest) == 0 {
+			ds.Service.log().Warn("bad digest", "field", "DS" + n, "value", digest)
+			return nil, &ParseError{Page: "dnssec", Field: "DS" + n, Msg: "is not a hex digest"}
 		}
 
 		records = append(records, DNSSEC {
 			KeyTag: uint16(keyTag),
 			Algorithm: uint8(algorithm),
-			Digest: digests[i][1],
+			Digest: digest,
 		})
 	}
 
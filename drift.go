@@ -0,0 +1,79 @@
+package pchome
+
+import (
+	"sort"
+	"time"
+)
+
+// 漂移報告，比較本地組態和網站上的記錄。
+type DriftReport struct {
+	CheckedAt time.Time
+	// 比較的 zone 數量。
+	Checked int
+	// 有差異的 zone，變更以本地組態為前、網站為後。
+	Zones map[string][]Change
+	// 無法取得網站記錄的 zone 和錯誤訊息。
+	Errors map[string]string `json:",omitempty"`
+}
+
+// 是否有任何 zone 和網站不同。
+func (r *DriftReport) Drifted() bool {
+	return len(r.Zones) > 0
+}
+
+// 有差異的 zone 名稱，依名稱排序。
+func (r *DriftReport) Names() []string {
+	names := make([]string, 0, len(r.Zones))
+	for name := range r.Zones {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// 並行取得 names 的網站記錄，和本地組態比較。names 為空時比較組態裡所有的 zone。
+// 無法取得記錄的 zone 記在報告的 Errors，不會中斷其他 zone。
+func (cs *ConfigService) Drift(s *Service, names []string) (*DriftReport, error) {
+	config, err := cs.Read()
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		names = make([]string, 0, len(config.Zones))
+		for name := range config.Zones {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+
+	opt := cs.syncOptions()
+	opt.TTL = 0
+	live, err := cs.syncZones(s, names, opt)
+	report := &DriftReport {
+		CheckedAt: time.Now(),
+		Checked: len(names),
+		Zones: make(map[string][]Change),
+	}
+	if syncErr, ok := err.(*SyncError); ok {
+		report.Errors = make(map[string]string)
+		for name, err := range syncErr.Errors {
+			report.Errors[name] = err.Error()
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	for name, zone := range live {
+		changes := DiffZone(config.Zones[name], zone)
+		if len(changes) == 0 {
+			continue
+		}
+		report.Zones[name] = changes
+		for _, change := range changes {
+			cs.log().Warn("drift detected", LogZone, name, "change", change.String())
+		}
+	}
+
+	return report, nil
+}
@@ -0,0 +1,72 @@
+package pchome
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+)
+
+func TestDrift(t *testing.T) {
+	raw := map[string][]byte {
+		"/manage/dns_edit.htm": fixture(t, "ns_full.html"),
+		"/manage/set_dnssec.htm": fixture(t, "dnssec_empty.html"),
+	}
+
+	dir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Chdir(dir)
+
+	cs := NewConfigService()
+	config := Config {
+		Zones: map[string]Zone {
+			"example.com": {NS: NS{"ns0.example.com": "192.0.2.9"}},
+			"example.net": {},
+		},
+	}
+	if err := cs.Save(&config); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	s := NewService("key")
+	s.Limiter = nil
+	s.Client = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		status := http.StatusOK
+		if req.URL.Query().Get("dn") != "example.com" {
+			status = http.StatusInternalServerError
+		}
+		return &http.Response {
+			StatusCode: status,
+			Header: http.Header{"Content-Type": []string{"text/html; charset=utf-8"}},
+			Body: ioutil.NopCloser(bytes.NewReader(raw[req.URL.Path])),
+			Request: req,
+		}, nil
+	})}
+
+	report, err := cs.Drift(s, nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if report.Checked != 2 || len(report.Errors) != 1 || len(report.Errors["example.net"]) == 0 {
+		t.Errorf("Report checked %d zones with errors %v.", report.Checked, report.Errors)
+	}
+	if !report.Drifted() || len(report.Names()) != 1 {
+		t.Fatalf("Report has drifted zones %v.", report.Names())
+	}
+
+	found := false
+	for _, change := range report.Zones["example.com"] {
+		if change.String() == "~ns ns0.example.com 192.0.2.9 -> 192.0.2.1" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Report misses the changed glue ip, %v.", report.Zones["example.com"])
+	}
+}
@@ -0,0 +1,322 @@
+package pchome
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// PChome 代管 DNS 的記錄類型，FWD 為網址轉址。
+const (
+	HostA = "A"
+	HostAAAA = "AAAA"
+	HostCNAME = "CNAME"
+	HostMX = "MX"
+	HostTXT = "TXT"
+	HostForward = "FWD"
+)
+
+// 每個 zone 最多的代管記錄數。
+const maxHostRecords = 10
+
+// zone 使用自管 DNS，代管記錄不會生效。
+var ErrNotHosted = errors.New("The zone uses its own name servers, PChome DNS records are not in effect.")
+
+// PChome 代管 DNS 的記錄，zone 使用 PChome DNS 時才生效。
+type HostRecord struct {
+	// 子網域，空字串表示 zone 本身。
+	Name string
+	Type string
+	// 記錄內容，MX 為「優先權 主機」，FWD 為轉址網址。
+	Content string
+}
+
+// 代管 DNS 服務結構。
+type HostService struct {
+	Service *Service
+}
+
+// 取得代管 DNS 服務。
+func (s *Service) NewHostService() *HostService {
+	return &HostService {
+		Service: s,
+	}
+}
+
+// 列舉 PChome 網站的代管記錄，zone 使用自管 DNS 時回傳 ErrNotHosted。
+func (hs *HostService) List(zone string) ([]HostRecord, error) {
+	p, err := hs.page(zone)
+	if err != nil {
+		return nil, err
+	}
+	if p.Inputs["dns_mode"] != "0" {
+		return nil, ErrNotHosted
+	}
+
+	records, err := hs.parse(p)
+	if err != nil {
+		return nil, hs.Service.parseFailed(err)
+	}
+	return records, nil
+}
+
+// 添加代管記錄。
+func (hs *HostService) Add(zone string, record HostRecord) error {
+	records, err := hs.List(zone)
+	if err != nil {
+		return err
+	}
+	for _, r := range records {
+		if r == record {
+			return errors.New("Duplicated host record.")
+		}
+	}
+
+	return hs.Set(zone, append(records, record))
+}
+
+// 移除代管記錄。
+func (hs *HostService) Delete(zone string, record HostRecord) error {
+	records, err := hs.List(zone)
+	if err != nil {
+		return err
+	}
+
+	rest := make([]HostRecord, 0, len(records))
+	for _, r := range records {
+		if r != record {
+			rest = append(rest, r)
+		}
+	}
+	if len(rest) == len(records) {
+		return errors.New("No matched host record.")
+	}
+
+	return hs.Set(zone, rest)
+}
+
+// 以 records 取代 zone 的所有代管記錄，保留轉址記錄原本的標題和描述。
+// zone 使用自管 DNS 時回傳 ErrNotHosted，不會切換成 PChome DNS。
+func (hs *HostService) Set(zone string, records []HostRecord) error {
+	log := hs.Service.log().With(LogOperation, "host.set", LogZone, zone)
+	if len(records) > maxHostRecords {
+		log.Error("more than 10 host records", "count", len(records))
+		return errors.New("A zone can have at most 10 host records.")
+	}
+	for _, r := range records {
+		if err := validHostRecord(r); err != nil {
+			log.Error("bad host record", "name", r.Name, "type", r.Type, LogError, err)
+			return err
+		}
+	}
+
+	p, err := hs.page(zone)
+	if err != nil {
+		return err
+	}
+	if p.Inputs["dns_mode"] != "0" {
+		log.Error("zone uses its own name servers")
+		return ErrNotHosted
+	}
+	old, err := hs.parse(p)
+	if err != nil {
+		return hs.Service.parseFailed(err)
+	}
+
+	urlstr := ENDPOINT + "/dns_edit.php"
+	body, err := encodeForm(hs.preparePostData(zone, p, records), hs.Service.formCharset(hostOf(urlstr), ""))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", urlstr, strings.NewReader(body))
+	if err != nil {
+		log.Error("creates http request failed", LogError, err)
+		return errors.New("Creating http request failed.")
+	}
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	hs.Service.SetCookie(req)
+
+	resp, err := hs.Service.Do(req)
+	if err != nil {
+		log.Error("requesting failed", LogError, err)
+		return errors.New("Having http requesting failed.")
+	}
+	if err := checkStatus(log, resp); err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	changed := countHostChanges(old, records)
+	log.Info("saved host records", "changed", changed)
+	hs.Service.observer().ObserveRecordsChanged(zone, "host", changed)
+
+	return nil
+}
+
+// 檢查代管記錄的類型和內容。
+func validHostRecord(r HostRecord) error {
+	if len(r.Content) == 0 {
+		return errors.New("Empty host record content.")
+	}
+
+	switch r.Type {
+	case HostA, HostAAAA, HostCNAME, HostTXT, HostForward:
+	case HostMX:
+		fields := strings.Fields(r.Content)
+		if len(fields) != 2 {
+			return errors.New("MX content should be a preference and a host.")
+		}
+		if _, err := strconv.ParseUint(fields[0], 10, 16); err != nil {
+			return errors.New("Bad MX preference " + fields[0] + ".")
+		}
+	default:
+		return errors.New("Unsupported host record type " + r.Type + ".")
+	}
+
+	return nil
+}
+
+// 計算兩組代管記錄相差的筆數。
+func countHostChanges(old, records []HostRecord) int {
+	count := make(map[HostRecord]int)
+	for _, r := range old {
+		count[r]++
+	}
+	for _, r := range records {
+		count[r]--
+	}
+
+	changed := 0
+	for _, n := range count {
+		if n < 0 {
+			n = -n
+		}
+		changed += n
+	}
+
+	return changed
+}
+
+// 取得並解析 zone 的 DNS 設定網頁。
+func (hs *HostService) page(zone string) (*page, error) {
+	log := hs.Service.log().With(LogZone, zone)
+	if len(zone) == 0 {
+		log.Error("has empty zone name")
+		return nil, errors.New("Empty zone name.")
+	}
+
+	urlstr := ENDPOINT + "/dns_edit.htm?dn=" + url.QueryEscape(zone)
+	req, err := http.NewRequest("GET", urlstr, nil)
+	if err != nil {
+		log.Error("creates request failed", LogError, err)
+		return nil, errors.New("Cannot create a http request.")
+	}
+	hs.Service.SetCookie(req)
+
+	resp, err := hs.Service.Do(req)
+	if err != nil {
+		log.Error("requesting failed", LogError, err)
+		return nil, errors.New("Having http requesting failed.")
+	}
+	if err := checkStatus(log, resp); err != nil {
+		return nil, err
+	}
+
+	b, err := hs.Service.readBody(resp)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	p := parsePage(b)
+	if p.isLogin() {
+		log.Error("gets the login page")
+		return nil, hs.Service.parseFailed(&ParseError{Page: "host", Msg: "is the login page"})
+	}
+	return p, nil
+}
+
+// 依欄位編號配對 subhostf、typef 和 contentf，略過沒有內容的欄位。
+func (hs *HostService) parse(p *page) ([]HostRecord, error) {
+	contents := p.slots("contentf")
+	if len(contents) == 0 {
+		hs.Service.log().Error("has no contentf fields")
+		return nil, &ParseError{Page: "host", Field: "contentf", Msg: "is missing"}
+	}
+	names := p.slots("subhostf")
+	types := p.slots("typef")
+
+	records := make([]HostRecord, 0)
+	for idx := 0; idx < len(contents); idx++ {
+		content, ok := contents[idx]
+		if !ok || len(content) == 0 {
+			continue
+		}
+
+		r := HostRecord {
+			Name: strings.ToLower(names[idx]),
+			Type: strings.ToUpper(types[idx]),
+			Content: content,
+		}
+		if err := validHostRecord(r); err != nil {
+			field := "typef" + strconv.Itoa(idx)
+			hs.Service.log().Warn("bad host record", "field", field, LogError, err)
+			return nil, &ParseError{Page: "host", Field: field, Msg: "has unsupported type " + types[idx]}
+		}
+		records = append(records, r)
+	}
+
+	return records, nil
+}
+
+// 準備提交的表單資料，轉址記錄沿用網頁上相同記錄的標題和描述。
+func (hs *HostService) preparePostData(zone string, p *page, records []HostRecord) url.Values {
+	data := url.Values{}
+
+	for i := 0; i < 5; i++ {
+		data.Add("host_dn" + strconv.Itoa(i), "")
+		data.Add("host_ip" + strconv.Itoa(i), "")
+		data.Add("host_ipv6" + strconv.Itoa(i), "")
+	}
+
+	fwd := make(map[HostRecord]int)
+	for idx, content := range p.slots("contentf") {
+		r := HostRecord {
+			Name: strings.ToLower(p.Inputs["subhostf" + strconv.Itoa(idx)]),
+			Type: strings.ToUpper(p.Inputs["typef" + strconv.Itoa(idx)]),
+			Content: content,
+		}
+		if r.Type == HostForward {
+			fwd[r] = idx
+		}
+	}
+
+	for i := 0; i < maxHostRecords; i++ {
+		n := strconv.Itoa(i)
+		data.Add("subhostf" + n, "")
+		data.Add("contentf" + n, "")
+		data.Add("typef" + n, "fwd")
+		data.Add("fwd_titlef" + n, "")
+		data.Add("fwd_meta_tagf" + n, "")
+		data.Add("fwd_description_tagf" + n, "")
+	}
+	for i, r := range records {
+		n := strconv.Itoa(i)
+		data.Set("subhostf" + n, r.Name)
+		data.Set("contentf" + n, r.Content)
+		data.Set("typef" + n, strings.ToLower(r.Type))
+		if idx, ok := fwd[r]; ok {
+			old := strconv.Itoa(idx)
+			data.Set("fwd_titlef" + n, p.Inputs["fwd_titlef" + old])
+			data.Set("fwd_meta_tagf" + n, p.Inputs["fwd_meta_tagf" + old])
+			data.Set("fwd_description_tagf" + n, p.Inputs["fwd_description_tagf" + old])
+		}
+	}
+
+	data.Add("dn", zone)
+	data.Add("dns_mode", "0")
+
+	return data
+}
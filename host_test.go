@@ -0,0 +1,88 @@
+package pchome
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+// 回傳固定網頁並記錄提交表單的 HTTP client。
+func hostClient(t *testing.T, file string, posted *url.Values) *http.Client {
+	raw := fixture(t, file)
+	return &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if req.Method == "POST" {
+			b, _ := ioutil.ReadAll(req.Body)
+			*posted, _ = url.ParseQuery(string(b))
+		}
+		return &http.Response {
+			StatusCode: http.StatusOK,
+			Header: http.Header{"Content-Type": []string{"text/html; charset=utf-8"}},
+			Body: ioutil.NopCloser(bytes.NewReader(raw)),
+			Request: req,
+		}, nil
+	})}
+}
+
+func TestHostList(t *testing.T) {
+	var posted url.Values
+	s := NewService("key")
+	s.Limiter = nil
+	s.Client = hostClient(t, "host_records.html", &posted)
+
+	records, err := s.NewHostService().List("example.com")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	want := []HostRecord {
+		{"", HostA, "192.0.2.10"},
+		{"www", HostCNAME, "example.com"},
+		{"", HostMX, "10 mail.example.com"},
+		{"_acme-challenge", HostTXT, "token"},
+		{"blog", HostForward, "http://blog.example.net/"},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("Records %v, want %v.", records, want)
+	}
+
+	s.Client = hostClient(t, "ns_full.html", &posted)
+	if _, err := s.NewHostService().List("example.com"); err != ErrNotHosted {
+		t.Errorf("Self hosted zone has error %v, want ErrNotHosted.", err)
+	}
+	if err := s.NewHostService().Set("example.com", nil); err != ErrNotHosted || posted != nil {
+		t.Errorf("Setting a self hosted zone has error %v and posts %v.", err, posted)
+	}
+}
+
+func TestHostSet(t *testing.T) {
+	var posted url.Values
+	s := NewService("key")
+	s.Limiter = nil
+	s.Client = hostClient(t, "host_records.html", &posted)
+
+	hs := s.NewHostService()
+	if err := hs.Add("example.com", HostRecord{"_acme-challenge", HostTXT, "token"}); err == nil {
+		t.Error("Adding a duplicated record succeeds.")
+	}
+	if err := hs.Set("example.com", []HostRecord{{"www", "SRV", "0 5 80 www"}}); err == nil || posted != nil {
+		t.Errorf("Setting an unsupported type has error %v and posts %v.", err, posted)
+	}
+
+	if err := hs.Delete("example.com", HostRecord{"www", HostCNAME, "example.com"}); err != nil {
+		t.Fatal(err.Error())
+	}
+	if posted.Get("dns_mode") != "0" || posted.Get("dn") != "example.com" {
+		t.Errorf("Posted mode %q zone %q.", posted.Get("dns_mode"), posted.Get("dn"))
+	}
+	if posted.Get("subhostf1") != "" || posted.Get("typef1") != "mx" || posted.Get("contentf1") != "10 mail.example.com" {
+		t.Errorf("Posted second record %q %q %q.", posted.Get("subhostf1"), posted.Get("typef1"), posted.Get("contentf1"))
+	}
+	if posted.Get("typef3") != "fwd" || posted.Get("fwd_titlef3") != "Blog" {
+		t.Errorf("Forwarding record lost its title, %q %q.", posted.Get("typef3"), posted.Get("fwd_titlef3"))
+	}
+	if posted.Get("contentf4") != "" {
+		t.Errorf("Deleted slot has content %q.", posted.Get("contentf4"))
+	}
+}
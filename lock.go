@@ -0,0 +1,87 @@
+package pchome
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 預設的組態鎖檔案位置。
+const DefaultLockPath = ".pchome.lock"
+
+// 預設等待組態鎖的時間。
+const DefaultLockWait = 2 * time.Minute
+
+// 等待鎖釋放時的輪詢間隔。
+const lockPoll = 200 * time.Millisecond
+
+// 檔案鎖，以獨佔建立的檔案避免多個行程同時寫入組態。
+type FileLock struct {
+	path string
+}
+
+// 取得 path 的檔案鎖，已被其他行程持有時等到釋放或 ctx 結束。
+// 檔案內容為持有者的 pid，行程異常結束留下的鎖需要手動刪除。
+func LockFile(ctx context.Context, path string) (*FileLock, error) {
+	for {
+		f, err := os.OpenFile(path, os.O_WRONLY | os.O_CREATE | os.O_EXCL, 0600)
+		if err == nil {
+			_, err = f.WriteString(strconv.Itoa(os.Getpid()) + "\n")
+			f.Close()
+			if err != nil {
+				os.Remove(path)
+				return nil, errors.New("Writing lock file failed.")
+			}
+			return &FileLock{path: path}, nil
+		}
+		if !os.IsExist(err) {
+			return nil, errors.New("Creating lock file failed, " + err.Error() + ".")
+		}
+
+		timer := time.NewTimer(lockPoll)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, errors.New("The configuration is locked by pid " + lockHolder(path) + ", remove " + path + " if the process is gone.")
+		case <-timer.C:
+		}
+	}
+}
+
+// 讀取持有鎖的 pid。
+func lockHolder(path string) string {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "unknown"
+	}
+
+	return strings.TrimSpace(string(b))
+}
+
+// 釋放檔案鎖。
+func (l *FileLock) Unlock() error {
+	if err := os.Remove(l.path); err != nil && !os.IsNotExist(err) {
+		return errors.New("Removing lock file failed.")
+	}
+
+	return nil
+}
+
+// 持有 DefaultLockPath 的檔案鎖執行 fn，最多等待 DefaultLockWait。
+// 沒有設定 Lock 的 dnsprovider、terraform 和 registrar 以此保護組態寫入。
+func WithLock(fn func() error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultLockWait)
+	defer cancel()
+
+	lock, err := LockFile(ctx, DefaultLockPath)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	return fn()
+}
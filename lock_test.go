@@ -0,0 +1,41 @@
+package pchome
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLockFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lock")
+
+	lock, err := LockFile(context.Background(), path)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300 * time.Millisecond)
+	defer cancel()
+	if _, err := LockFile(ctx, path); err == nil {
+		t.Fatal("Locking a held lock should fail.")
+	}
+
+	released := make(chan error, 1)
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		released <- lock.Unlock()
+	}()
+	ctx, cancel = context.WithTimeout(context.Background(), 5 * time.Second)
+	defer cancel()
+	next, err := LockFile(ctx, path)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := <-released; err != nil {
+		t.Error(err.Error())
+	}
+	if err := next.Unlock(); err != nil {
+		t.Error(err.Error())
+	}
+}
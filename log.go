@@ -0,0 +1,124 @@
+package pchome
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+)
+
+// 記錄欄位名稱。
+const (
+	LogZone = "zone"
+	LogOperation = "operation"
+	LogStatus = "status"
+	LogError = "err"
+)
+
+// 需要遮蔽的欄位名稱。
+var secretNames = map[string]bool {
+	"key": true,
+	"pass": true,
+	"mbrpass": true,
+	"loginkuser": true,
+	"session": true,
+	"authorization": true,
+}
+
+// 名稱含有這些字樣的欄位也需要遮蔽。
+var secretParts = []string{"password", "secret", "token", "cookie"}
+
+// 是否為需要遮蔽的欄位名稱。
+func secretKey(name string) bool {
+	name = strings.ToLower(name)
+	if secretNames[name] {
+		return true
+	}
+
+	for _, part := range secretParts {
+		if strings.Contains(name, part) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// 遮蔽秘密的 slog handler，密碼、存取鑰匙、cookie 和 token 等欄位會被替換。
+type RedactHandler struct {
+	handler slog.Handler
+}
+
+// 取得包裝 h 的遮蔽 handler。
+func NewRedactHandler(h slog.Handler) *RedactHandler {
+	return &RedactHandler {
+		handler: h,
+	}
+}
+
+func (rh *RedactHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return rh.handler.Enabled(ctx, level)
+}
+
+func (rh *RedactHandler) Handle(ctx context.Context, r slog.Record) error {
+	redactedRecord := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	r.Attrs(func(attr slog.Attr) bool {
+		redactedRecord.AddAttrs(redactAttr(attr))
+		return true
+	})
+
+	return rh.handler.Handle(ctx, redactedRecord)
+}
+
+func (rh *RedactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redactedAttrs := make([]slog.Attr, 0, len(attrs))
+	for _, attr := range attrs {
+		redactedAttrs = append(redactedAttrs, redactAttr(attr))
+	}
+
+	return NewRedactHandler(rh.handler.WithAttrs(redactedAttrs))
+}
+
+func (rh *RedactHandler) WithGroup(name string) slog.Handler {
+	return NewRedactHandler(rh.handler.WithGroup(name))
+}
+
+// 遮蔽欄位，群組會逐層處理。
+func redactAttr(attr slog.Attr) slog.Attr {
+	if attr.Value.Kind() == slog.KindGroup {
+		attrs := attr.Value.Group()
+		redactedAttrs := make([]any, 0, len(attrs))
+		for _, a := range attrs {
+			redactedAttrs = append(redactedAttrs, redactAttr(a))
+		}
+		return slog.Group(attr.Key, redactedAttrs...)
+	}
+
+	if secretKey(attr.Key) {
+		return slog.String(attr.Key, redacted)
+	}
+
+	return attr
+}
+
+// 取得預設記錄器，輸出到 slog.Default 並遮蔽秘密。
+func defaultLogger() *slog.Logger {
+	return slog.New(NewRedactHandler(slog.Default().Handler()))
+}
+
+// 取得服務的記錄器，未設定時使用預設記錄器。
+func (s *Service) log() *slog.Logger {
+	if s == nil || s.Logger == nil {
+		return defaultLogger()
+	}
+
+	return s.Logger
+}
+
+// 取得組態服務的記錄器，未設定時使用預設記錄器。
+func (cs *ConfigService) log() *slog.Logger {
+	if cs == nil || cs.Logger == nil {
+		return defaultLogger()
+	}
+
+	return cs.Logger
+}
@@ -0,0 +1,24 @@
+package pchome
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestRedactHandler(t *testing.T) {
+	var buf bytes.Buffer
+	log := slog.New(NewRedactHandler(slog.NewTextHandler(&buf, nil)))
+
+	log.With("loginkuser", "secret-key").Info("login", LogZone, "example.com",
+		slog.Group("form", "mbrpass", "secret-password", "mbrid", "user@example.com"))
+
+	out := buf.String()
+	if strings.Contains(out, "secret-key") || strings.Contains(out, "secret-password") {
+		t.Errorf("Log leaks secrets, %s", out)
+	}
+	if !strings.Contains(out, "zone=example.com") || !strings.Contains(out, "form.mbrid=user@example.com") {
+		t.Errorf("Log drops fields, %s", out)
+	}
+}
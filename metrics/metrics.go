@@ -0,0 +1,132 @@
+// Package metrics 以 Prometheus 指標實作 pchome.Observer。
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/a2n/pchome"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// 指標名稱的前綴。
+const namespace = "pchome"
+
+// Prometheus 收集器，設定到 Service.Observer 或 ConfigService.Observer。
+type Collector struct {
+	registry *prometheus.Registry
+
+	requests *prometheus.CounterVec
+	latency *prometheus.HistogramVec
+	logins *prometheus.CounterVec
+	parseFailures *prometheus.CounterVec
+	zonesSynced *prometheus.CounterVec
+	recordsChanged *prometheus.CounterVec
+	dnsChecks *prometheus.CounterVec
+}
+
+var _ pchome.Observer = (*Collector)(nil)
+
+// 取得收集器，指標註冊在自己的 registry，並附帶 Go runtime 和 process 指標。
+func NewCollector() *Collector {
+	c := &Collector {
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts {
+			Namespace: namespace,
+			Name: "http_requests_total",
+			Help: "HTTP requests to PChome by endpoint and status, status 0 for connection errors.",
+		}, []string{"endpoint", "status"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts {
+			Namespace: namespace,
+			Name: "http_request_duration_seconds",
+			Help: "Latency of HTTP requests to PChome by endpoint.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"endpoint"}),
+		logins: prometheus.NewCounterVec(prometheus.CounterOpts {
+			Namespace: namespace,
+			Name: "logins_total",
+			Help: "Logins to PChome by result.",
+		}, []string{"result"}),
+		parseFailures: prometheus.NewCounterVec(prometheus.CounterOpts {
+			Namespace: namespace,
+			Name: "parse_failures_total",
+			Help: "Pages that failed to parse by page.",
+		}, []string{"page"}),
+		zonesSynced: prometheus.NewCounterVec(prometheus.CounterOpts {
+			Namespace: namespace,
+			Name: "zones_synced_total",
+			Help: "Zones synced from PChome by result.",
+		}, []string{"result"}),
+		recordsChanged: prometheus.NewCounterVec(prometheus.CounterOpts {
+			Namespace: namespace,
+			Name: "records_changed_total",
+			Help: "Records changed on PChome by kind.",
+		}, []string{"kind"}),
+		dnsChecks: prometheus.NewCounterVec(prometheus.CounterOpts {
+			Namespace: namespace,
+			Name: "dns_checks_total",
+			Help: "DNS delegation checks by result.",
+		}, []string{"result"}),
+	}
+
+	c.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		c.requests,
+		c.latency,
+		c.logins,
+		c.parseFailures,
+		c.zonesSynced,
+		c.recordsChanged,
+		c.dnsChecks,
+	)
+
+	return c
+}
+
+// 取得 /metrics 的 HTTP handler。
+func (c *Collector) Handler() http.Handler {
+	return promhttp.HandlerFor(c.registry, promhttp.HandlerOpts{})
+}
+
+// 取得收集器的 registry，可以再註冊其他指標。
+func (c *Collector) Registry() *prometheus.Registry {
+	return c.registry
+}
+
+// 以錯誤決定結果標籤。
+func result(err error) string {
+	if err != nil {
+		return "failure"
+	}
+
+	return "success"
+}
+
+func (c *Collector) ObserveRequest(endpoint string, status int, d time.Duration) {
+	c.requests.WithLabelValues(endpoint, strconv.Itoa(status)).Inc()
+	c.latency.WithLabelValues(endpoint).Observe(d.Seconds())
+}
+
+func (c *Collector) ObserveLogin(err error) {
+	c.logins.WithLabelValues(result(err)).Inc()
+}
+
+func (c *Collector) ObserveParseFailure(page string) {
+	c.parseFailures.WithLabelValues(page).Inc()
+}
+
+func (c *Collector) ObserveZoneSynced(zone string, err error) {
+	c.zonesSynced.WithLabelValues(result(err)).Inc()
+}
+
+func (c *Collector) ObserveRecordsChanged(zone, kind string, n int) {
+	c.recordsChanged.WithLabelValues(kind).Add(float64(n))
+}
+
+func (c *Collector) ObserveDNSCheck(zone string, err error) {
+	c.dnsChecks.WithLabelValues(result(err)).Inc()
+}
@@ -0,0 +1,50 @@
+package metrics
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/a2n/pchome"
+)
+
+func TestCollector(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	c := NewCollector()
+	s := pchome.NewService("key")
+	s.Observer = c
+
+	req, err := http.NewRequest("GET", server.URL + "/manage/index.htm", nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	resp, err := s.Do(req)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	resp.Body.Close()
+	c.ObserveRecordsChanged("example.com", "ns", 2)
+
+	rec := httptest.NewRecorder()
+	c.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	b, err := ioutil.ReadAll(rec.Body)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for _, want := range []string{
+		`pchome_http_requests_total{endpoint="/manage/index.htm",status="200"} 1`,
+		`pchome_http_request_duration_seconds_count{endpoint="/manage/index.htm"} 1`,
+		`pchome_records_changed_total{kind="ns"} 2`,
+	} {
+		if !strings.Contains(string(b), want) {
+			t.Errorf("Metrics miss %s.", want)
+		}
+	}
+}
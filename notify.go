@@ -0,0 +1,96 @@
+package pchome
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"time"
+)
+
+// 通知事件的種類。
+const (
+	// 透過 NSService 或 DNSSECService 異動記錄。
+	EventChange = "change"
+	// ConfigService.Update 發現網站上的記錄和本地組態不同。
+	EventUpdate = "update"
+)
+
+// 送出通知的時間上限。
+const notifyTimeout = 30 * time.Second
+
+// 通知事件。
+type Event struct {
+	// EventChange 或 EventUpdate。
+	Type string
+	Time time.Time
+	User string
+	Profile string
+	Zone string
+	// 異動名稱，EventUpdate 時為 update，網站上新增或刪除的 zone 為 add 或 delete。
+	Operation string
+	Changes []Change
+}
+
+// 通知者，例如 notify 套件的 webhook 和 email。實作必須可以並行呼叫。
+type Notifier interface {
+	Notify(ctx context.Context, event Event) error
+}
+
+// 送出通知，失敗只記錄警告，不影響已經完成的異動。
+func notify(n Notifier, log *slog.Logger, event Event) {
+	if n == nil || len(event.Changes) == 0 {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	if len(event.User) == 0 {
+		event.User = osUser()
+	}
+	if len(event.Profile) == 0 {
+		event.Profile = profile()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+	defer cancel()
+	if err := n.Notify(ctx, event); err != nil {
+		log.Warn("notify failed", LogOperation, event.Operation, LogZone, event.Zone, LogError, err)
+	}
+}
+
+// 通知 Update 前後記錄有差異的 zone。網站上新增或刪除的 zone 以 add 或 delete 通知，
+// 變更包含全部的 NS 和 DNSSEC 記錄。
+func (cs *ConfigService) notifyUpdate(before, after map[string]Zone) {
+	if cs.Notifier == nil {
+		return
+	}
+
+	names := make([]string, 0, len(after))
+	for name := range after {
+		names = append(names, name)
+	}
+	for name := range before {
+		if _, ok := after[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		_, existed := before[name]
+		_, exists := after[name]
+		op := "update"
+		switch {
+		case !existed:
+			op = "add"
+		case !exists:
+			op = "delete"
+		}
+		notify(cs.Notifier, cs.log(), Event {
+			Type: EventUpdate,
+			Zone: name,
+			Operation: op,
+			Changes: DiffZone(before[name], after[name]),
+		})
+	}
+}
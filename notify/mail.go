@@ -0,0 +1,127 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/a2n/pchome"
+)
+
+// Email 通知者，以 SMTP 寄出事件，伺服器支援時使用 STARTTLS。
+type Mail struct {
+	// SMTP 伺服器，host:port。
+	Addr string
+	// 認證帳號，空字串時不認證。
+	Username string
+	Password string
+	From string
+	To []string
+	Subject *template.Template
+	Body *template.Template
+}
+
+// 取得使用預設範本的 email 通知者。
+func NewMail(addr, from string, to []string) *Mail {
+	return &Mail {
+		Addr: addr,
+		From: from,
+		To: to,
+		Subject: template.Must(Parse("subject", "", DefaultSubject)),
+		Body: template.Must(Parse("body", "", DefaultBody)),
+	}
+}
+
+// 產生郵件內容，包含標頭。
+func (m *Mail) message(event pchome.Event) ([]byte, error) {
+	subject, err := render(m.Subject, event)
+	if err != nil {
+		return nil, err
+	}
+	body, err := render(m.Body, event)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("From: " + m.From + "\r\n")
+	buf.WriteString("To: " + strings.Join(m.To, ", ") + "\r\n")
+	buf.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", strings.TrimSpace(subject)) + "\r\n")
+	buf.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("\r\n")
+	buf.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+
+	return buf.Bytes(), nil
+}
+
+func (m *Mail) Notify(ctx context.Context, event pchome.Event) error {
+	if len(m.To) == 0 {
+		return errors.New("No mail recipient.")
+	}
+
+	msg, err := m.message(event)
+	if err != nil {
+		return err
+	}
+
+	host, _, err := net.SplitHostPort(m.Addr)
+	if err != nil {
+		return errors.New("Bad SMTP address " + m.Addr + ".")
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", m.Addr)
+	if err != nil {
+		return errors.New("Connecting SMTP server failed, " + err.Error() + ".")
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return errors.New("Connecting SMTP server failed, " + err.Error() + ".")
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return errors.New("SMTP STARTTLS failed, " + err.Error() + ".")
+		}
+	}
+	if len(m.Username) > 0 {
+		if err := c.Auth(smtp.PlainAuth("", m.Username, m.Password, host)); err != nil {
+			return errors.New("SMTP authentication failed, " + err.Error() + ".")
+		}
+	}
+
+	if err := c.Mail(m.From); err != nil {
+		return errors.New("SMTP MAIL failed, " + err.Error() + ".")
+	}
+	for _, to := range m.To {
+		if err := c.Rcpt(to); err != nil {
+			return errors.New("SMTP RCPT " + to + " failed, " + err.Error() + ".")
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return errors.New("SMTP DATA failed, " + err.Error() + ".")
+	}
+	if _, err := w.Write(msg); err != nil {
+		return errors.New("Writing mail failed, " + err.Error() + ".")
+	}
+	if err := w.Close(); err != nil {
+		return errors.New("Sending mail failed, " + err.Error() + ".")
+	}
+
+	return c.Quit()
+}
@@ -0,0 +1,172 @@
+// Package notify 實作 pchome.Notifier，把記錄異動送到 webhook、email 或本地檔案。
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"text/template"
+
+	"github.com/a2n/pchome"
+)
+
+// 預設的通知標題範本。
+const DefaultSubject = `[pchome] {{.Operation}} {{.Zone}}`
+
+// 預設的通知內容範本，列出每一筆變更。
+const DefaultBody = `{{.Time.Format "2006-01-02 15:04:05 MST"}} {{.User}} {{.Operation}} {{.Zone}}
+{{range .Changes}}{{.}}
+{{end}}`
+
+// 解析通知範本，text 為空時使用 fallback。
+func Parse(name, text, fallback string) (*template.Template, error) {
+	if len(text) == 0 {
+		text = fallback
+	}
+
+	t, err := template.New(name).Parse(text)
+	if err != nil {
+		return nil, errors.New("Parse " + name + " template failed, " + err.Error() + ".")
+	}
+
+	return t, nil
+}
+
+// 以範本產生通知文字。
+func render(t *template.Template, event pchome.Event) (string, error) {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, event); err != nil {
+		return "", errors.New("Execute " + t.Name() + " template failed, " + err.Error() + ".")
+	}
+
+	return buf.String(), nil
+}
+
+// 依序通知多個通知者，全部送出後回傳合併的錯誤。
+type Multi []pchome.Notifier
+
+func (m Multi) Notify(ctx context.Context, event pchome.Event) error {
+	msgs := make([]string, 0)
+	for _, n := range m {
+		if err := n.Notify(ctx, event); err != nil {
+			msgs = append(msgs, err.Error())
+		}
+	}
+	if len(msgs) > 0 {
+		return errors.New(strings.Join(msgs, " "))
+	}
+
+	return nil
+}
+
+// 通知設定檔，JSON 格式。範本欄位可以是範本文字，或以 @ 開頭的範本檔案路徑。
+type Config struct {
+	Webhooks []WebhookConfig
+	Mails []MailConfig
+	// 本地測試用的檔案，- 表示標準輸出。
+	Sink string
+}
+
+// Webhook 設定。
+type WebhookConfig struct {
+	URL string
+	Header map[string]string
+	// 請求內容範本，空字串時送出事件的 JSON。
+	Template string
+	ContentType string
+}
+
+// Email 設定。
+type MailConfig struct {
+	// SMTP 伺服器，host:port。
+	Addr string
+	Username string
+	Password string
+	From string
+	To []string
+	Subject string
+	Body string
+}
+
+// 讀取通知設定檔，回傳設定的所有通知者。
+func Load(path string) (Multi, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.New("Read notify config file failed, " + err.Error() + ".")
+	}
+
+	var config Config
+	if err := json.Unmarshal(b, &config); err != nil {
+		return nil, errors.New("Unmarshal notify config json failed, " + err.Error() + ".")
+	}
+
+	return config.Notifiers()
+}
+
+// 依設定建立所有通知者。
+func (c Config) Notifiers() (Multi, error) {
+	m := make(Multi, 0)
+	for _, wc := range c.Webhooks {
+		text, err := templateText(wc.Template)
+		if err != nil {
+			return nil, err
+		}
+		w := NewWebhook(wc.URL)
+		if len(text) > 0 {
+			if w.Template, err = Parse("webhook", text, ""); err != nil {
+				return nil, err
+			}
+		}
+		for k, v := range wc.Header {
+			w.Header.Set(k, v)
+		}
+		if len(wc.ContentType) > 0 {
+			w.ContentType = wc.ContentType
+		}
+		m = append(m, w)
+	}
+
+	for _, mc := range c.Mails {
+		subject, err := templateText(mc.Subject)
+		if err != nil {
+			return nil, err
+		}
+		body, err := templateText(mc.Body)
+		if err != nil {
+			return nil, err
+		}
+		mail := NewMail(mc.Addr, mc.From, mc.To)
+		mail.Username = mc.Username
+		mail.Password = mc.Password
+		if mail.Subject, err = Parse("subject", subject, DefaultSubject); err != nil {
+			return nil, err
+		}
+		if mail.Body, err = Parse("body", body, DefaultBody); err != nil {
+			return nil, err
+		}
+		m = append(m, mail)
+	}
+
+	if len(c.Sink) > 0 {
+		m = append(m, NewFileSink(c.Sink))
+	}
+
+	return m, nil
+}
+
+// 取得範本文字，@ 開頭時讀取檔案。
+func templateText(s string) (string, error) {
+	if !strings.HasPrefix(s, "@") {
+		return s, nil
+	}
+
+	b, err := ioutil.ReadFile(s[1:])
+	if err != nil {
+		return "", errors.New("Read template file failed, " + err.Error() + ".")
+	}
+
+	return string(b), nil
+}
@@ -0,0 +1,107 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/a2n/pchome"
+)
+
+// 測試用的事件。
+func event() pchome.Event {
+	return pchome.Event {
+		Type: pchome.EventChange,
+		Time: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		User: "alice",
+		Zone: "example.com",
+		Operation: "ns.update",
+		Changes: []pchome.Change {
+			{Kind: "ns", Action: pchome.ChangeUpdate, Name: "ns0.example.com", Before: "10.0.0.0", After: "10.0.0.1"},
+		},
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	var got pchome.Event
+	var auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "sink.txt")
+	config := Config {
+		Webhooks: []WebhookConfig{{URL: server.URL, Header: map[string]string{"Authorization": "Bearer token"}}},
+		Sink: path,
+	}
+	n, err := config.Notifiers()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := n.Notify(context.Background(), event()); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if auth != "Bearer token" || got.Zone != "example.com" || len(got.Changes) != 1 {
+		t.Errorf("Webhook got %+v with authorization %q.", got, auth)
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !strings.Contains(string(b), "alice ns.update example.com") || !strings.Contains(string(b), "~ns ns0.example.com 10.0.0.0 -> 10.0.0.1") {
+		t.Errorf("Sink wrote %q.", b)
+	}
+}
+
+func TestWebhookTemplate(t *testing.T) {
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		body = string(b)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	w := NewWebhook(server.URL)
+	w.Template = parse(t, `{"text": "{{.Zone}} changed by {{.User}}"}`)
+	if err := w.Notify(context.Background(), event()); err == nil {
+		t.Error("A failed webhook should return an error.")
+	}
+	if body != `{"text": "example.com changed by alice"}` {
+		t.Errorf("Webhook got %q.", body)
+	}
+}
+
+func TestMailMessage(t *testing.T) {
+	m := NewMail("localhost:25", "pchome@example.com", []string{"oncall@example.com"})
+	msg, err := m.message(event())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	for _, want := range []string{"To: oncall@example.com\r\n", "Subject: [pchome] ns.update example.com\r\n", "~ns ns0.example.com 10.0.0.0 -> 10.0.0.1\r\n"} {
+		if !strings.Contains(string(msg), want) {
+			t.Errorf("Mail misses %q in %q.", want, msg)
+		}
+	}
+}
+
+func parse(t *testing.T, text string) *template.Template {
+	tmpl, err := Parse("test", text, "")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return tmpl
+}
@@ -0,0 +1,73 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"text/template"
+
+	"github.com/a2n/pchome"
+)
+
+// 本地測試用的通知者，保留收到的事件，並可以把通知文字寫到檔案或標準輸出。
+type Sink struct {
+	// 寫入的檔案，- 表示標準輸出，空字串時只保留事件。
+	Path string
+	Template *template.Template
+
+	mu sync.Mutex
+	events []pchome.Event
+}
+
+// 取得只保留事件的通知者。
+func NewSink() *Sink {
+	return &Sink {
+		Template: template.Must(Parse("sink", "", DefaultBody)),
+	}
+}
+
+// 取得把通知文字附加到 path 的通知者，path 為 - 時寫到標準輸出。
+func NewFileSink(path string) *Sink {
+	s := NewSink()
+	s.Path = path
+	return s
+}
+
+func (s *Sink) Notify(ctx context.Context, event pchome.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, event)
+
+	if len(s.Path) == 0 {
+		return nil
+	}
+	text, err := render(s.Template, event)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if s.Path != "-" {
+		f, err := os.OpenFile(s.Path, os.O_WRONLY | os.O_APPEND | os.O_CREATE, 0600)
+		if err != nil {
+			return errors.New("Opening sink file failed, " + err.Error() + ".")
+		}
+		defer f.Close()
+		w = f
+	}
+	if _, err := io.WriteString(w, text); err != nil {
+		return errors.New("Writing sink failed, " + err.Error() + ".")
+	}
+
+	return nil
+}
+
+// 取得收到的事件。
+func (s *Sink) Events() []pchome.Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]pchome.Event{}, s.events...)
+}
@@ -0,0 +1,78 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"text/template"
+
+	"github.com/a2n/pchome"
+)
+
+// Webhook 通知者，以 POST 送出事件。
+type Webhook struct {
+	URL string
+	// 額外的請求標頭，例如認證。
+	Header http.Header
+	// 請求內容範本，nil 時送出事件的 JSON。
+	Template *template.Template
+	ContentType string
+	// HTTP client，nil 時使用 http.DefaultClient。
+	Client *http.Client
+}
+
+// 取得送出 JSON 的 webhook 通知者。
+func NewWebhook(url string) *Webhook {
+	return &Webhook {
+		URL: url,
+		Header: http.Header{},
+		ContentType: "application/json",
+	}
+}
+
+func (w *Webhook) Notify(ctx context.Context, event pchome.Event) error {
+	var body string
+	if w.Template != nil {
+		text, err := render(w.Template, event)
+		if err != nil {
+			return err
+		}
+		body = text
+	} else {
+		b, err := json.Marshal(event)
+		if err != nil {
+			return errors.New("Marshal event json failed.")
+		}
+		body = string(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", w.URL, strings.NewReader(body))
+	if err != nil {
+		return errors.New("Creating webhook request failed, " + err.Error() + ".")
+	}
+	for k, v := range w.Header {
+		req.Header[k] = append([]string{}, v...)
+	}
+	req.Header.Set("Content-Type", w.ContentType)
+
+	client := w.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return errors.New("Posting webhook failed, " + err.Error() + ".")
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.New("Webhook responds " + resp.Status + ".")
+	}
+
+	return nil
+}
@@ -0,0 +1,59 @@
+package pchome
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+// 保留事件的通知者。
+type recordNotifier struct {
+	mu sync.Mutex
+	events []Event
+}
+
+func (r *recordNotifier) Notify(ctx context.Context, event Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+	return nil
+}
+
+func TestNotifyUpdate(t *testing.T) {
+	n := &recordNotifier{}
+	cs := NewConfigService()
+	cs.Notifier = n
+
+	before := map[string]Zone {
+		"example.com": {NS: NS{"ns0.example.com": "10.0.0.0"}},
+		"example.net": {NS: NS{"ns0.example.net": ""}},
+	}
+	after := map[string]Zone {
+		"example.com": {NS: NS{"ns0.example.com": "10.0.0.0"}, DNSSEC: []DNSSEC{{KeyTag: 1, Algorithm: 13, Digest: "ab"}}},
+		"example.net": {NS: NS{"ns0.example.net": ""}},
+		"example.org": {NS: NS{"ns0.example.org": ""}},
+	}
+	cs.notifyUpdate(before, after)
+
+	if len(n.events) != 2 {
+		t.Fatalf("Notified events %+v.", n.events)
+	}
+	e := n.events[0]
+	if e.Type != EventUpdate || e.Zone != "example.com" || e.Operation != "update" || len(e.Changes) != 1 || e.Changes[0].String() != "+dnssec 1 13 AB" || e.Time.IsZero() {
+		t.Errorf("Notified event %+v.", e)
+	}
+	e = n.events[1]
+	if e.Zone != "example.org" || e.Operation != "add" || len(e.Changes) != 1 || e.Changes[0].Kind != "ns" {
+		t.Errorf("Notified event %+v.", e)
+	}
+
+	n.events = nil
+	cs.notifyUpdate(after, before)
+	if len(n.events) != 2 {
+		t.Fatalf("Notified events %+v.", n.events)
+	}
+	e = n.events[1]
+	if e.Zone != "example.org" || e.Operation != "delete" || len(e.Changes) != 1 || e.Changes[0].Kind != "ns" {
+		t.Errorf("Notified event %+v.", e)
+	}
+}
@@ -2,19 +2,78 @@ package pchome
 
 import (
 	"net/http"
-	"io/ioutil"
-	"regexp"
+	"net"
 	"strings"
 	"net/url"
 	"errors"
 	"strconv"
-
-	"github.com/a2n/alu"
 )
 
-// Name server 結構。
+// Name server 結構，主機名稱對應 glue。glue 可以為空、一個 IPv4 或 IPv6 位址，
+// 或以逗號分隔的 IPv4 和 IPv6 位址，例如 192.0.2.1,2001:db8::1。
 type NS map[string]string
 
+// 拆開 glue 的 IPv4 和 IPv6 位址。
+func SplitGlue(glue string) (ipv4, ipv6 string) {
+	for _, ip := range strings.Split(glue, ",") {
+		ip = strings.TrimSpace(ip)
+		if strings.Contains(ip, ":") {
+			ipv6 = ip
+		} else if len(ip) > 0 {
+			ipv4 = ip
+		}
+	}
+
+	return ipv4, ipv6
+}
+
+// 合併 IPv4 和 IPv6 位址成 glue，IPv4 在前。
+func JoinGlue(ipv4, ipv6 string) string {
+	if len(ipv4) == 0 || len(ipv6) == 0 {
+		return ipv4 + ipv6
+	}
+
+	return ipv4 + "," + ipv6
+}
+
+// 是否為合法的 glue，最多一個 IPv4 和一個 IPv6 位址，可以為空。
+func ValidGlue(glue string) bool {
+	if len(glue) == 0 {
+		return true
+	}
+	ips := strings.Split(glue, ",")
+	if len(ips) > 2 {
+		return false
+	}
+	ipv4, ipv6 := 0, 0
+	for _, ip := range ips {
+		addr := net.ParseIP(strings.TrimSpace(ip))
+		switch {
+		case addr == nil:
+			return false
+		case addr.To4() != nil && !strings.Contains(ip, ":"):
+			ipv4++
+		default:
+			ipv6++
+		}
+	}
+
+	return ipv4 <= 1 && ipv6 <= 1
+}
+
+// 正規化 glue 以便比較，IPv6 位址轉成最短的形式。
+func normalizeGlue(glue string) string {
+	ipv4, ipv6 := SplitGlue(glue)
+	if addr := net.ParseIP(ipv4); addr != nil {
+		ipv4 = addr.String()
+	}
+	if addr := net.ParseIP(ipv6); addr != nil {
+		ipv6 = addr.String()
+	}
+
+	return JoinGlue(ipv4, ipv6)
+}
+
 // NS 服務結構。
 type NSService struct {
 	Service *Service
@@ -25,95 +84,94 @@ type NSService struct {
 
 // 添加 NS 記錄。
 func (ns *NSService) Add(zone, name, ip string) error {
-	ns.cs = NewConfigService()
+	ns.cs = ns.Service.configService()
 	config, err := ns.cs.Read()
 	if err != nil {
 		return err
 	}
 	ns.config = config
+	log := ns.Service.log().With(LogOperation, "ns.add", LogZone, zone)
 
 	// Zone
 	if _, ok := ns.config.Zones[zone]; !ok {
-		logger.Printf("%s has no such zone name, %s.", alu.Caller(), zone)
+		log.Error("no such zone name")
 		return errors.New("No such zone name.")
 	}
 	ns.zone = zone
 
 	if len(ns.config.Zones[ns.zone].NS) == 5 {
-		logger.Printf("%s, zone(%s) is reaching the max NS record count 5.", alu.Caller(), zone)
+		log.Error("reaching the max NS record count 5")
 		return errors.New("The zone is reaching the max NS record count 5, delete some records first.")
 	}
 
 	// Name
 	if _, ok := ns.config.Zones[ns.zone].NS[name]; ok {
-		logger.Printf("%s has duplicated host name, %s.", alu.Caller(), name)
+		log.Error("duplicated host name", "name", name)
 		return errors.New("Duplicated host name.")
 	}
 
 	ns.config.Zones[ns.zone].NS[name] = ip
-	ns.save()
-
-	return nil
+	return ns.save("ns.add", 1)
 }
 
 // 移除 NS 記錄。
 func (ns *NSService) Delete(zone, name, ip string) error {
-	ns.cs = NewConfigService()
+	ns.cs = ns.Service.configService()
 	config, err := ns.cs.Read()
 	if err != nil {
 		return err
 	}
 	ns.config = config
+	log := ns.Service.log().With(LogOperation, "ns.delete", LogZone, zone)
 
 	// Zone
 	if _, ok := ns.config.Zones[zone]; !ok {
-		logger.Printf("%s has no matched zone name, %s.", alu.Caller(), zone)
+		log.Error("no matched zone name")
 		return errors.New("No matched zone name.")
 	}
 	ns.zone = zone
 
 	// Name
 	if _, ok := ns.config.Zones[ns.zone].NS[name]; !ok {
-		logger.Printf("%s no matched host name, %s.", alu.Caller(), name)
+		log.Error("no matched host name", "name", name)
 		return errors.New("No matched host name.")
 	}
 
 	// IP
-	if ns.config.Zones[ns.zone].NS[name] != ip {
-		logger.Printf("%s has no matched recrods, %s.", alu.Caller, ip)
+	if normalizeGlue(ns.config.Zones[ns.zone].NS[name]) != normalizeGlue(ip) {
+		log.Error("no matched ip", "name", name, "ip", ip)
 		return errors.New("No matched ip.")
 	}
 
 	delete(ns.config.Zones[ns.zone].NS, name)
-	ns.save()
-
-	return nil
+	return ns.save("ns.delete", 1)
 }
 
 // 更新 NS 記錄。
 func (ns *NSService) Update(zone, name, ip string) error {
-	ns.cs = NewConfigService()
+	ns.cs = ns.Service.configService()
 	config, err := ns.cs.Read()
 	if err != nil {
 		return err
 	}
 	ns.config = config
+	log := ns.Service.log().With(LogOperation, "ns.update", LogZone, zone)
 
 	// Zone
 	if _, ok := ns.config.Zones[zone]; !ok {
-		logger.Printf("%s has no matched zone name, %s.", alu.Caller(), zone)
+		log.Error("no matched zone name")
 		return errors.New("No matched zone name.")
 	}
 	ns.zone = zone
 
 	// Name
 	if _, ok := ns.config.Zones[ns.zone].NS[name]; !ok {
-		logger.Printf("%s no matched host name, %s.", alu.Caller(), name)
+		log.Error("no matched host name", "name", name)
 		return errors.New("No matched host name.")
 	}
 
 	ns.config.Zones[ns.zone].NS[name] = ip
-	err = ns.save()
+	err = ns.save("ns.update", 1)
 	if err != nil {
 		return err
 	}
@@ -121,25 +179,85 @@ func (ns *NSService) Update(zone, name, ip string) error {
 	return nil
 }
 
-// 提交 NS 記錄到 PChome 網站。
-func (ns *NSService) save() error {
-	reader := strings.NewReader(ns.preparePostData().Encode())
+// 以 record 取代 zone 的所有 NS 記錄。
+func (ns *NSService) Set(zone string, record NS) error {
+	ns.cs = ns.Service.configService()
+	config, err := ns.cs.Read()
+	if err != nil {
+		return err
+	}
+	ns.config = config
+	log := ns.Service.log().With(LogOperation, "ns.set", LogZone, zone)
+
+	// Zone
+	zoneObj, ok := ns.config.Zones[zone]
+	if !ok {
+		log.Error("no matched zone name")
+		return errors.New("No matched zone name.")
+	}
+	ns.zone = zone
+
+	if len(record) > 5 {
+		log.Error("more than 5 NS records", "count", len(record))
+		return errors.New("A zone can have at most 5 NS records.")
+	}
+
+	changed := len(DiffZone(Zone{NS: zoneObj.NS}, Zone{NS: record}))
+	zoneObj.NS = make(NS)
+	for name, ip := range record {
+		zoneObj.NS[name] = ip
+	}
+	ns.config.Zones[zone] = zoneObj
+
+	return ns.save("ns.set", changed)
+}
+
+// 提交 NS 記錄到 PChome 網站，提交前先存快照，提交後寫入稽核日誌，op 為異動名稱，changed 為異動的記錄數。
+func (ns *NSService) save(op string, changed int) (err error) {
+	log := ns.Service.log().With(LogOperation, op, LogZone, ns.zone)
+	snapshot, err := ns.cs.takeSnapshot(op, ns.zone)
+	if err != nil {
+		return err
+	}
+	before := ns.cs.currentZone(ns.zone)
+	status := 0
+	defer func() {
+		ns.cs.audit(op, ns.zone, snapshot, before, ns.config.Zones[ns.zone], status, err)
+	}()
+
 	urlstr := ENDPOINT + "/dns_edit.php"
+	body, err := encodeForm(ns.preparePostData(), ns.Service.formCharset(hostOf(urlstr), ns.config.Charset))
+	if err != nil {
+		return err
+	}
+	reader := strings.NewReader(body)
 	req, err := http.NewRequest("POST", urlstr, reader)
 	if err != nil {
-		logger.Printf("%s creates http request failed, %s.", alu.Caller(), err.Error())
+		log.Error("creates http request failed", LogError, err)
 		return errors.New("Creating http request failed.")
 	}
 	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 	ns.Service.SetCookie(req)
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := ns.Service.Do(req)
 	if err != nil {
-		logger.Printf("%s requesting failed, %s.", alu.Caller(), err.Error())
+		log.Error("requesting failed", LogError, err)
 		return errors.New("Having http requesting failed.")
 	}
+	status = resp.StatusCode
+	if err := checkStatus(log, resp); err != nil {
+		return err
+	}
 	resp.Body.Close()
-	if err := ns.cs.Save(&ns.config); err != nil {
+	log.Info("saved NS records", "changed", changed)
+	ns.Service.observer().ObserveRecordsChanged(ns.zone, "ns", changed)
+	notify(ns.Service.Notifier, log, Event {
+		Type: EventChange,
+		Zone: ns.zone,
+		Operation: op,
+		Changes: DiffZone(before, ns.config.Zones[ns.zone]),
+	})
+	if err := ns.cs.SaveZone(ns.zone, ns.config.Zones[ns.zone]); err != nil {
 		return err
 	}
 
@@ -155,10 +273,13 @@ func (ns *NSService) preparePostData() url.Values {
 		data.Add("host_ip" + strconv.Itoa(i), "")
 		data.Add("host_ipv6" + strconv.Itoa(i), "")
 	}
+	// glue 的 IPv4 和 IPv6 位址分別放在 host_ip 和 host_ipv6，和 parse 對應。
 	idx := 0
-	for name, ip := range ns.config.Zones[ns.zone].NS {
+	for name, glue := range ns.config.Zones[ns.zone].NS {
+		ipv4, ipv6 := SplitGlue(glue)
 		data.Set("host_dn" + strconv.Itoa(idx), name)
-		data.Set("host_ip" + strconv.Itoa(idx), ip)
+		data.Set("host_ip" + strconv.Itoa(idx), ipv4)
+		data.Set("host_ipv6" + strconv.Itoa(idx), ipv6)
 		idx++
 	}
 
@@ -177,58 +298,111 @@ func (ns *NSService) preparePostData() url.Values {
 	return data
 }
 
+// 是否為合法的主機名稱，結尾的點可有可無。
+func ValidHost(name string) bool {
+	name = strings.TrimSuffix(name, ".")
+	if len(name) == 0 || len(name) > 253 {
+		return false
+	}
+
+	for _, label := range strings.Split(name, ".") {
+		if len(label) == 0 || len(label) > 63 || label[0] == '-' || label[len(label) - 1] == '-' {
+			return false
+		}
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-') {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
 // 列舉 PChome 網站的 NS 記錄。
 func (ns *NSService) List(zone string) (NS, error) {
+	log := ns.Service.log().With(LogZone, zone)
 	if len(zone) == 0 {
-		logger.Printf("%s has empty zone name.", alu.Caller())
+		log.Error("has empty zone name")
 	}
 
 	urlstr := "http://myname.pchome.com.tw/manage/dns_edit.htm?dn=" + zone
 	req, err := http.NewRequest("GET", urlstr, nil)
 	if err != nil {
-		logger.Printf("%s creates request failed, %s.", alu.Caller(), err.Error())
+		log.Error("creates request failed", LogError, err)
 		return nil, errors.New("Cannot create a http request.")
 	}
 	ns.Service.SetCookie(req)
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := ns.Service.Do(req)
 	if err != nil {
-		logger.Printf("%s requesting failed, %s.", alu.Caller(), err.Error())
+		log.Error("requesting failed", LogError, err)
 		return nil, errors.New("Having http requesting failed.")
 	}
+	if err := checkStatus(log, resp); err != nil {
+		return nil, err
+	}
 
-	b, err := ioutil.ReadAll(resp.Body)
+	b, err := ns.Service.readBody(resp)
 	if err != nil {
-		logger.Printf("%s reads http body failed, %s.", alu.Caller(), err.Error())
-		return nil, errors.New("Reading http body failed.")
+		resp.Body.Close()
+		return nil, err
 	}
 	resp.Body.Close()
 	slice, err := ns.parse(b)
 	if err != nil {
-		return nil, err
+		return nil, ns.Service.parseFailed(err)
 	}
 	return slice, nil
 }
 
-// 解析 PChome DNSSEC 網頁。
+// 解析 PChome NS 網頁，依欄位編號配對 host_dn、host_ip 和 host_ipv6。
+// 兩者都有的主機以 JoinGlue 合併成一個 glue。
 func (ns *NSService) parse(raw []byte) (NS, error) {
 	if len(raw) == 0 {
-		logger.Printf("%s has empty raw.", alu.Caller())
+		ns.Service.log().Error("has empty raw")
 		return nil, errors.New("Empty raw content to parse.")
 	}
 
-	reName := regexp.MustCompile(`host_dn\d" value="((?:\w+\.)+\w+)"`)
-	names := reName.FindAllStringSubmatch(string(raw), -1)
-	reIP := regexp.MustCompile(`host_ip\d" value="((?:\d{1,3}\.){3}\d{1,3})"`)
-	ips := reIP.FindAllStringSubmatch(string(raw), -1)
-	if len(names) != len(ips) {
-		logger.Printf("%s has difference results.", alu.Caller())
-		return nil, errors.New("NS data does not match regex patterns.")
+	p := parsePage(raw)
+	if p.isLogin() {
+		ns.Service.log().Error("gets the login page")
+		return nil, &ParseError{Page: "ns", Msg: "is the login page"}
+	}
+
+	names := p.slots("host_dn")
+	if len(names) == 0 {
+		ns.Service.log().Error("has no host_dn fields")
+		return nil, &ParseError{Page: "ns", Field: "host_dn", Msg: "is missing"}
 	}
+	ips := p.slots("host_ip")
+	ipv6s := p.slots("host_ipv6")
 
 	record := make(NS)
-	for i := 0; i < len(names); i++ {
-		record[names[i][1]] = ips[i][1]
+	for idx, name := range names {
+		field := "host_dn" + strconv.Itoa(idx)
+		ip := JoinGlue(ips[idx], ipv6s[idx])
+
+		if len(name) == 0 {
+			if len(ip) > 0 {
+				ns.Service.log().Warn("ip without host name", "field", field, "ip", ip)
+				return nil, &ParseError{Page: "ns", Field: field, Msg: "is empty but has an ip"}
+			}
+			continue
+		}
+
+		name = strings.ToLower(name)
+		if _, ok := record[name]; ok {
+			ns.Service.log().Warn("duplicated host name", "field", field, "name", name)
+			return nil, &ParseError{Page: "ns", Field: field, Msg: "is duplicated"}
+		}
+		for _, slot := range []struct{ field, ip string }{{"host_ip", ips[idx]}, {"host_ipv6", ipv6s[idx]}} {
+			if len(slot.ip) > 0 && net.ParseIP(slot.ip) == nil {
+				ns.Service.log().Warn("bad ip", "field", slot.field + strconv.Itoa(idx), "ip", slot.ip)
+				return nil, &ParseError{Page: "ns", Field: slot.field + strconv.Itoa(idx), Msg: "is not an ip"}
+			}
+		}
+		record[name] = ip
 	}
 
 	return record, nil
@@ -0,0 +1,113 @@
+package pchome
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 觀察者，接收請求、登入、解析、同步、異動和 DNS 檢查的事件，
+// 例如 metrics 套件的 Prometheus 收集器。實作必須可以並行呼叫。
+type Observer interface {
+	// 對 PChome 的一次 HTTP 請求，連線失敗時 status 為 0。
+	ObserveRequest(endpoint string, status int, d time.Duration)
+	// 一次登入，err 為 nil 表示成功。
+	ObserveLogin(err error)
+	// 一次解析失敗，page 為 ParseError.Page。
+	ObserveParseFailure(page string)
+	// 一個 zone 的同步結果。
+	ObserveZoneSynced(zone string, err error)
+	// 提交到網站的記錄異動，kind 為 ns 或 dnssec。
+	ObserveRecordsChanged(zone, kind string, n int)
+	// 一次 DNS 檢查的結果。
+	ObserveDNSCheck(zone string, err error)
+}
+
+// 什麼都不做的觀察者。
+type nopObserver struct{}
+
+func (nopObserver) ObserveRequest(string, int, time.Duration) {}
+func (nopObserver) ObserveLogin(error) {}
+func (nopObserver) ObserveParseFailure(string) {}
+func (nopObserver) ObserveZoneSynced(string, error) {}
+func (nopObserver) ObserveRecordsChanged(string, string, int) {}
+func (nopObserver) ObserveDNSCheck(string, error) {}
+
+// 取得服務的觀察者，未設定時不做任何事。
+func (s *Service) observer() Observer {
+	if s == nil || s.Observer == nil {
+		return nopObserver{}
+	}
+
+	return s.Observer
+}
+
+// 取得組態服務的觀察者，未設定時不做任何事。
+func (cs *ConfigService) observer() Observer {
+	if cs == nil || cs.Observer == nil {
+		return nopObserver{}
+	}
+
+	return cs.Observer
+}
+
+// 記錄解析失敗，回傳原本的錯誤。
+func (s *Service) parseFailed(err error) error {
+	var pe *ParseError
+	if errors.As(err, &pe) {
+		s.observer().ObserveParseFailure(pe.Page)
+	}
+
+	return err
+}
+
+// 檢查 zone 在 DNS 公開的 NS 記錄是否和組態一致，不一致時回傳錯誤。
+func (cs *ConfigService) CheckDelegation(ctx context.Context, zone string) error {
+	err := cs.checkDelegation(ctx, zone)
+	cs.observer().ObserveDNSCheck(zone, err)
+	if err != nil {
+		cs.log().Warn("dns check failed", LogZone, zone, LogError, err)
+	}
+
+	return err
+}
+
+func (cs *ConfigService) checkDelegation(ctx context.Context, zone string) error {
+	config, err := cs.Read()
+	if err != nil {
+		return err
+	}
+	z, ok := config.Zones[zone]
+	if !ok {
+		return errors.New("No matched zone name.")
+	}
+	// 沒有自訂 NS 時使用 PChome 的預設 NS，無從比對。
+	if len(z.NS) == 0 {
+		return nil
+	}
+
+	records, err := net.DefaultResolver.LookupNS(ctx, zone)
+	if err != nil {
+		return errors.New("Lookup NS of " + zone + " failed, " + err.Error() + ".")
+	}
+
+	published := make([]string, 0, len(records))
+	for _, r := range records {
+		published = append(published, strings.ToLower(strings.TrimSuffix(r.Host, ".")))
+	}
+	configured := make([]string, 0, len(z.NS))
+	for name := range z.NS {
+		configured = append(configured, strings.ToLower(strings.TrimSuffix(name, ".")))
+	}
+	sort.Strings(published)
+	sort.Strings(configured)
+
+	if strings.Join(published, " ") != strings.Join(configured, " ") {
+		return errors.New("Published NS " + strings.Join(published, ", ") + " differ from configured " + strings.Join(configured, ", ") + ".")
+	}
+
+	return nil
+}
@@ -0,0 +1,156 @@
+package pchome
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// 解析錯誤，網頁結構和預期不同時回傳。
+type ParseError struct {
+	// 解析的網頁，例如 zone list、ns 或 dnssec。
+	Page string
+	// 有問題的欄位，可為空。
+	Field string
+	Msg string
+}
+
+func (e *ParseError) Error() string {
+	if len(e.Field) == 0 {
+		return "Parse " + e.Page + " page failed, " + e.Msg + "."
+	}
+
+	return "Parse " + e.Page + " page failed, field " + e.Field + " " + e.Msg + "."
+}
+
+// 網頁連結。
+type pageLink struct {
+	Href string
+	Text string
+}
+
+// 解析後的網頁，包含表單欄位和連結。
+type page struct {
+	// 欄位名稱對應的值。
+	Inputs map[string]string
+	Links []pageLink
+}
+
+// 用 HTML tokenizer 讀取網頁的表單欄位和連結。
+// checkbox 和 radio 只取勾選的值，select 取選取的 option，沒有選取時取第一個。
+func parsePage(raw []byte) *page {
+	p := &page {
+		Inputs: make(map[string]string),
+		Links: make([]pageLink, 0),
+	}
+
+	z := html.NewTokenizer(bytes.NewReader(raw))
+	var link *pageLink
+	var textarea, selectName string
+	selected := false
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			return p
+		}
+
+		t := z.Token()
+		switch tt {
+		case html.StartTagToken, html.SelfClosingTagToken:
+			attrs := attrMap(t.Attr)
+			switch t.Data {
+			case "input":
+				name := attrs["name"]
+				if len(name) == 0 {
+					continue
+				}
+				switch strings.ToLower(attrs["type"]) {
+				case "checkbox", "radio":
+					if _, ok := attrs["checked"]; !ok {
+						continue
+					}
+				case "submit", "button", "image", "reset":
+					continue
+				}
+				p.Inputs[name] = strings.TrimSpace(attrs["value"])
+			case "textarea":
+				textarea = attrs["name"]
+				if len(textarea) > 0 {
+					p.Inputs[textarea] = ""
+				}
+			case "select":
+				selectName = attrs["name"]
+				selected = false
+			case "option":
+				if len(selectName) == 0 || selected {
+					continue
+				}
+				_, isSelected := attrs["selected"]
+				if _, ok := p.Inputs[selectName]; !ok || isSelected {
+					p.Inputs[selectName] = strings.TrimSpace(attrs["value"])
+					selected = isSelected
+				}
+			case "a":
+				link = &pageLink {
+					Href: attrs["href"],
+				}
+			}
+		case html.EndTagToken:
+			switch t.Data {
+			case "textarea":
+				textarea = ""
+			case "select":
+				selectName = ""
+			case "a":
+				if link != nil {
+					link.Text = strings.TrimSpace(link.Text)
+					p.Links = append(p.Links, *link)
+					link = nil
+				}
+			}
+		case html.TextToken:
+			if len(textarea) > 0 {
+				p.Inputs[textarea] += strings.TrimSpace(t.Data)
+			}
+			if link != nil {
+				link.Text += t.Data
+			}
+		}
+	}
+}
+
+// 把屬性轉成 map，名稱一律小寫。
+func attrMap(attrs []html.Attribute) map[string]string {
+	m := make(map[string]string, len(attrs))
+	for _, attr := range attrs {
+		m[strings.ToLower(attr.Key)] = attr.Val
+	}
+
+	return m
+}
+
+// 是否為登入頁。
+func (p *page) isLogin() bool {
+	_, ok := p.Inputs["mbrpass"]
+	return ok
+}
+
+// 取出名稱為 prefix 加上編號的欄位，回傳編號對應的值。
+func (p *page) slots(prefix string) map[int]string {
+	slots := make(map[int]string)
+	for name, value := range p.Inputs {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+
+		idx, err := strconv.Atoi(name[len(prefix):])
+		if err != nil || idx < 0 {
+			continue
+		}
+		slots[idx] = value
+	}
+
+	return slots
+}
@@ -0,0 +1,248 @@
+package pchome
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// 讀取測試網頁。
+func fixture(t testing.TB, name string) []byte {
+	b, err := ioutil.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	return b
+}
+
+// 以函式實作的 http.RoundTripper，套件外的測試改用 pchometest.Server。
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// 是否為 *ParseError。
+func isParseError(err error) bool {
+	var pe *ParseError
+	return errors.As(err, &pe)
+}
+
+func TestZoneListParse(t *testing.T) {
+	cases := []struct {
+		file string
+		zones []string
+		parseError bool
+	}{
+		{"zone_list_utf8.html", []string{"example.com", "example.com.tw", "xn--fiq228c.tw"}, false},
+		{"zone_list_empty.html", []string{}, false},
+		{"error.html", []string{}, false},
+		{"login.html", nil, true},
+	}
+
+	for _, c := range cases {
+		zones, err := (&ZoneListCall{}).Parse(fixture(t, c.file))
+		if c.parseError {
+			if !isParseError(err) {
+				t.Errorf("%s: error %v, want *ParseError.", c.file, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", c.file, err.Error())
+			continue
+		}
+
+		got := make([]string, 0)
+		for _, zone := range c.zones {
+			if _, ok := zones[zone]; ok {
+				got = append(got, zone)
+			}
+		}
+		if len(zones) != len(c.zones) || !reflect.DeepEqual(got, c.zones) {
+			t.Errorf("%s: zones %v, want %v.", c.file, zones, c.zones)
+		}
+	}
+}
+
+func TestNSParse(t *testing.T) {
+	cases := []struct {
+		file string
+		record NS
+		parseError bool
+	}{
+		{"ns_empty.html", NS{}, false},
+		{"ns_full.html", NS{
+			"ns0.example.com": "192.0.2.1",
+			"ns1.example.com": "192.0.2.2",
+			"ns-2.example.com": "192.0.2.3",
+			"ns3.example.com": "192.0.2.4",
+			"ns4.example.net": "",
+		}, false},
+		{"ns_ipv6.html", NS{
+			"ns0.example.com": "192.0.2.1,2001:db8::1",
+			"ns1.example.com": "2001:db8::2",
+		}, false},
+		{"ns_slots.html", NS{
+			"ns1.example.com": "192.0.2.1",
+			"ns10.example.com": "192.0.2.10,2001:db8::10",
+			"ns11.example.net": "",
+			"ns12.example.com": "2001:db8::12",
+		}, false},
+		{"login.html", nil, true},
+		{"error.html", nil, true},
+		{"dnssec_mixed.html", nil, true},
+	}
+
+	for _, c := range cases {
+		record, err := (&NSService{}).parse(fixture(t, c.file))
+		if c.parseError {
+			if !isParseError(err) {
+				t.Errorf("%s: error %v, want *ParseError.", c.file, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", c.file, err.Error())
+			continue
+		}
+		if !reflect.DeepEqual(record, c.record) {
+			t.Errorf("%s: records %v, want %v.", c.file, record, c.record)
+		}
+	}
+}
+
+// 解析後再提交的欄位要和原本網頁的欄位相同，每個主機的 IPv4 和 IPv6 都要保留。
+func TestNSPostRoundTrip(t *testing.T) {
+	for _, file := range []string{"ns_full.html", "ns_ipv6.html", "ns_slots.html"} {
+		raw := fixture(t, file)
+		p := parsePage(raw)
+		ips, ipv6s := p.slots("host_ip"), p.slots("host_ipv6")
+		// 主機名稱以小寫記錄。
+		want := make(map[string][2]string)
+		for idx, name := range p.slots("host_dn") {
+			if len(name) > 0 {
+				want[strings.ToLower(name)] = [2]string{ips[idx], ipv6s[idx]}
+			}
+		}
+
+		record, err := (&NSService{}).parse(raw)
+		if err != nil {
+			t.Fatalf("%s: %s", file, err.Error())
+		}
+		ns := &NSService {
+			config: Config{Zones: map[string]Zone{"example.com": {NS: record}}},
+			zone: "example.com",
+		}
+		data := ns.preparePostData()
+
+		posted := make(map[string][2]string)
+		for i := 0; i < 5; i++ {
+			n := strconv.Itoa(i)
+			if name := data.Get("host_dn" + n); len(name) > 0 {
+				posted[name] = [2]string{data.Get("host_ip" + n), data.Get("host_ipv6" + n)}
+			}
+		}
+		if !reflect.DeepEqual(posted, want) {
+			t.Errorf("%s: posted %v, want %v.", file, posted, want)
+		}
+	}
+}
+
+func TestDNSSECParse(t *testing.T) {
+	cases := []struct {
+		file string
+		records []DNSSEC
+		parseError bool
+	}{
+		{"dnssec_empty.html", []DNSSEC{}, false},
+		{"dnssec_mixed.html", []DNSSEC{
+			{2371, 13, "4355a46b19d348dc2f57c046f8ef63d4538ebb936000f3c9ee954a27460dd865"},
+			{60485, 8, "2BB183AF5F22588179A53B0A98631FAD1A292118"},
+			{31589, 14, "72d7b62976ce06438e9c0bf319013cf801f09ecc84b8d7e9495f27e305c6a9b0563a9b5f4d288405c3008a946df983d6"},
+		}, false},
+		{"login.html", nil, true},
+		{"error.html", nil, true},
+		{"ns_full.html", nil, true},
+	}
+
+	for _, c := range cases {
+		records, err := (&DNSSECService{}).parse(fixture(t, c.file))
+		if c.parseError {
+			if !isParseError(err) {
+				t.Errorf("%s: error %v, want *ParseError.", c.file, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", c.file, err.Error())
+			continue
+		}
+		if !reflect.DeepEqual(records, c.records) {
+			t.Errorf("%s: records %v, want %v.", c.file, records, c.records)
+		}
+	}
+}
+
+// 所有測試網頁，作為 fuzz 的種子。
+var fixtures = []string{
+	"zone_list_utf8.html",
+	"zone_list_empty.html",
+	"ns_empty.html",
+	"ns_full.html",
+	"ns_ipv6.html",
+	"dnssec_empty.html",
+	"dnssec_mixed.html",
+	"login.html",
+	"error.html",
+}
+
+func FuzzZoneListParse(f *testing.F) {
+	for _, name := range fixtures {
+		f.Add(fixture(f, name))
+	}
+
+	f.Fuzz(func(t *testing.T, raw []byte) {
+		zones, err := (&ZoneListCall{}).Parse(raw)
+		if err == nil && zones == nil {
+			t.Error("nil zones without error.")
+		}
+	})
+}
+
+func FuzzNSParse(f *testing.F) {
+	for _, name := range fixtures {
+		f.Add(fixture(f, name))
+	}
+
+	f.Fuzz(func(t *testing.T, raw []byte) {
+		record, err := (&NSService{}).parse(raw)
+		if err == nil && record == nil {
+			t.Error("nil record without error.")
+		}
+		if len(record) > 0 && err != nil {
+			t.Error("records with error.")
+		}
+	})
+}
+
+func FuzzDNSSECParse(f *testing.F) {
+	for _, name := range fixtures {
+		f.Add(fixture(f, name))
+	}
+
+	f.Fuzz(func(t *testing.T, raw []byte) {
+		records, err := (&DNSSECService{}).parse(raw)
+		if err == nil && records == nil {
+			t.Error("nil records without error.")
+		}
+		if len(records) > 0 && err != nil {
+			t.Error("records with error.")
+		}
+	})
+}
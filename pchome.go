@@ -1,16 +1,33 @@
 package pchome
 
 import (
+	"time"
 	"net/http"
-	"log"
-
-	"github.com/a2n/alu"
+	"net/url"
+	"log/slog"
+	"errors"
 )
 
 // PChome 服務結構。
 type Service struct {
 	Key string
-	Logger *log.Logger
+	// 結構化記錄器，nil 時使用遮蔽秘密的 slog.Default。
+	Logger *slog.Logger
+	// HTTP client，nil 時使用 http.DefaultClient。
+	Client *http.Client
+	// 提交表單的編碼，空字串時依網頁的編碼。
+	Charset string
+	// 重試策略，未設定的欄位使用 DefaultRetryPolicy。
+	Retry RetryPolicy
+
+	// 限速器，同一個 Service 建立的所有服務共用，nil 時不限速。
+	Limiter *RateLimiter
+	// 觀察者，nil 時不記錄指標。
+	Observer Observer
+	// 通知者，記錄異動後通知，nil 時不通知。
+	Notifier Notifier
+
+	charsets *charsetCache
 }
 
 // PChome 存取點網址。
@@ -18,21 +35,31 @@ const (
 	ENDPOINT = "http://myname.pchome.com.tw/manage"
 )
 
-// 記錄。
-var logger *log.Logger
-
 // 取得服務。
 func NewService(key string) *Service {
 	if len(key) == 0 {
-		logger.Printf("%s has empty key.", alu.Caller())
+		defaultLogger().Warn("new service with empty key")
 	}
 
 	return &Service {
 		Key: key,
-		Logger: alu.NewLogger("log"),
+		Limiter: NewRateLimiter(DefaultRate, DefaultBurst),
+		charsets: &charsetCache {
+			names: make(map[string]string),
+		},
 	}
 }
 
+// 取得網址的主機名稱。
+func hostOf(urlstr string) string {
+	u, err := url.Parse(urlstr)
+	if err != nil {
+		return ""
+	}
+
+	return u.Host
+}
+
 // 取得 zone 服務。
 func (s *Service) NewZoneService() *ZoneService {
 	return &ZoneService {
@@ -67,3 +94,67 @@ func (s *Service)SetCookie(req *http.Request) {
 
 	req.AddCookie(c)
 }
+
+// 送出 HTTP 請求，暫時性的失敗依重試策略重試。
+func (s *Service) Do(req *http.Request) (*http.Response, error) {
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	rp := s.retryPolicy()
+	for attempt := 1; ; attempt++ {
+		if s.Limiter != nil {
+			if err := s.Limiter.Wait(req.Context()); err != nil {
+				return nil, err
+			}
+		}
+
+		start := time.Now()
+		resp, err := client.Do(req)
+		status := 0
+		if err == nil {
+			status = resp.StatusCode
+		}
+		s.observer().ObserveRequest(req.URL.Path, status, time.Since(start))
+		log := s.log().With("method", req.Method, "path", req.URL.Path, "attempt", attempt, "duration", time.Since(start))
+		if err != nil {
+			log.Debug("http request failed", LogError, err)
+		} else {
+			log.Debug("http request", LogStatus, resp.StatusCode)
+		}
+
+		wait := retryAfter(resp)
+		if wait > 0 && s.Limiter != nil {
+			s.Limiter.Pause(time.Now().Add(wait))
+		}
+		if attempt >= rp.Attempts || !retryable(req, resp, err) || !rewind(req) {
+			return resp, err
+		}
+
+		if err != nil {
+			log.Warn("retry http request", "retries", rp.Attempts - 1, LogError, err)
+		} else {
+			log.Warn("retry http request", "retries", rp.Attempts - 1, LogStatus, resp.StatusCode)
+			resp.Body.Close()
+		}
+
+		if d := rp.backoff(attempt); d > wait {
+			wait = d
+		}
+		if err := sleep(req.Context(), wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// 檢查回應狀態，非 2xx 時關閉內容、記錄並回傳錯誤。
+func checkStatus(log *slog.Logger, resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	resp.Body.Close()
+	log.Error("unexpected http status", LogStatus, resp.StatusCode)
+	return errors.New("Unexpected http status " + resp.Status + ".")
+}
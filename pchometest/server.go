@@ -0,0 +1,371 @@
+// Package pchometest 提供模擬 PChome 網站的 HTTP 伺服器，測試時不用連到 PChome。
+//
+// 伺服器提供網域列表、NS、代管記錄和 DNSSEC 設定網頁，提交的表單會改變之後的網頁內容。
+// Client 回傳的 HTTP client 會把所有請求送到這個伺服器，設定到 Service.Client 即可。
+package pchometest
+
+import (
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/a2n/pchome"
+)
+
+// 網頁範本。
+var pages = template.Must(template.New("").Parse(`
+{{define "head"}}<!DOCTYPE html>
+<html>
+<head>
+<meta http-equiv="Content-Type" content="text/html; charset=utf-8">
+<title>PChome 買網址 - {{.}}</title>
+</head>
+<body>
+{{end}}
+{{define "index"}}{{template "head" "網域管理"}}<table class="domain_list">
+<tr><th>網域名稱</th><th>到期日</th><th>管理</th></tr>
+{{range .}}<tr><td>{{.}}</td><td>2099/12/31</td><td><a href="dns_edit.htm?dn={{.}}">進入</a></td></tr>
+{{end}}</table>
+</body>
+</html>
+{{end}}
+{{define "dns"}}{{template "head" "DNS 設定"}}<form name="dns" method="post" action="dns_edit.php">
+<input type="hidden" name="dn" value="{{.Name}}">
+<input type="radio" name="dns_mode" value="0"{{if .Hosted}} checked{{end}}>使用 PChome DNS
+<input type="radio" name="dns_mode" value="1"{{if not .Hosted}} checked{{end}}>自管 DNS
+<table>
+{{range $i, $ns := .NS}}<tr><td><input type="text" name="host_dn{{$i}}" value="{{$ns.Name}}"></td><td><input type="text" name="host_ip{{$i}}" value="{{$ns.IP}}"></td><td><input type="text" name="host_ipv6{{$i}}" value="{{$ns.IPv6}}"></td></tr>
+{{end}}</table>
+<table>
+{{range $i, $r := .Hosts}}<tr><td><input type="text" name="subhostf{{$i}}" value="{{$r.Name}}"></td><td><input type="text" name="typef{{$i}}" value="{{$r.Type}}"></td><td><input type="text" name="contentf{{$i}}" value="{{$r.Content}}"></td><td><input type="text" name="fwd_titlef{{$i}}" value="{{$r.Title}}"><input type="hidden" name="fwd_meta_tagf{{$i}}" value="{{$r.Meta}}"><input type="hidden" name="fwd_description_tagf{{$i}}" value="{{$r.Description}}"></td></tr>
+{{end}}</table>
+<input type="submit" name="submit" value="送出">
+</form>
+</body>
+</html>
+{{end}}
+{{define "dnssec"}}{{template "head" "DNSSEC 設定"}}<form name="dnssec" method="post" action="set_dnssec.php">
+<input type="hidden" name="dn" value="{{.Name}}">
+<table>
+{{range $i, $r := .DNSSEC}}<tr><td><input type="text" name="KeyTag{{$i}}" value="{{$r.KeyTag}}"></td><td><input type="text" name="alg{{$i}}" value="{{$r.Algorithm}}"></td><td><input type="text" name="DS{{$i}}" value="{{$r.Digest}}"></td></tr>
+{{end}}</table>
+</form>
+</body>
+</html>
+{{end}}
+{{define "error"}}{{template "head" "系統訊息"}}<p>{{.}}</p>
+<p><a href="index.htm">回到網域管理</a></p>
+</body>
+</html>
+{{end}}
+`))
+
+// 網頁上的 NS 欄位。
+type nsSlot struct {
+	Name string
+	IP string
+	IPv6 string
+}
+
+// 網頁上的 DNSSEC 欄位，空欄位的數字以空字串顯示。
+type dnssecSlot struct {
+	KeyTag string
+	Algorithm string
+	Digest string
+}
+
+// 網頁上的代管記錄欄位，類型為表單的小寫值，標題和描述只用於轉址記錄。
+type hostSlot struct {
+	Name string
+	Type string
+	Content string
+	Title string
+	Meta string
+	Description string
+}
+
+// 網域的內容。
+type zone struct {
+	hosted bool
+	ns []nsSlot
+	dnssec []dnssecSlot
+	hosts []hostSlot
+}
+
+// 模擬的 PChome 網站。
+type Server struct {
+	*httptest.Server
+
+	mu sync.Mutex
+	zones map[string]*zone
+	posts int
+}
+
+// 啟動沒有網域的模擬網站，用完需要 Close。
+func NewServer() *Server {
+	s := &Server {
+		zones: make(map[string]*zone),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+
+	return s
+}
+
+// 取得把所有請求送到模擬網站的 HTTP client。
+func (s *Server) Client() *http.Client {
+	target, _ := url.Parse(s.URL)
+	transport := s.Server.Client().Transport
+
+	return &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		req = req.Clone(req.Context())
+		req.URL.Scheme = target.Scheme
+		req.URL.Host = target.Host
+		req.Host = target.Host
+		return transport.RoundTrip(req)
+	})}
+}
+
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// 取得已登入模擬網站、不限速的服務。
+func (s *Server) Service() *pchome.Service {
+	svc := pchome.NewService("pchometest")
+	svc.Limiter = nil
+	svc.Client = s.Client()
+
+	return svc
+}
+
+// 添加或取代使用自管 DNS 的網域。
+func (s *Server) SetZone(name string, z pchome.Zone) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	v := &zone{}
+	for host, ip := range z.NS {
+		slot := nsSlot{Name: host}
+		slot.IP, slot.IPv6 = pchome.SplitGlue(ip)
+		v.ns = append(v.ns, slot)
+	}
+	sort.Slice(v.ns, func(i, j int) bool { return v.ns[i].Name < v.ns[j].Name })
+	for _, r := range z.DNSSEC {
+		v.dnssec = append(v.dnssec, dnssecSlot{strconv.Itoa(int(r.KeyTag)), strconv.Itoa(int(r.Algorithm)), r.Digest})
+	}
+	s.zones[name] = v
+}
+
+// 取得網域目前的 NS 和 DNSSEC 記錄。
+func (s *Server) Zone(name string) (pchome.Zone, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	v, ok := s.zones[name]
+	if !ok {
+		return pchome.Zone{}, false
+	}
+
+	z := pchome.Zone{NS: make(pchome.NS), DNSSEC: make([]pchome.DNSSEC, 0)}
+	for _, slot := range v.ns {
+		if len(slot.Name) == 0 {
+			continue
+		}
+		z.NS[strings.ToLower(slot.Name)] = pchome.JoinGlue(slot.IP, slot.IPv6)
+	}
+	for _, slot := range v.dnssec {
+		keyTag, err := strconv.ParseUint(slot.KeyTag, 10, 16)
+		if err != nil {
+			continue
+		}
+		algorithm, _ := strconv.ParseUint(slot.Algorithm, 10, 8)
+		z.DNSSEC = append(z.DNSSEC, pchome.DNSSEC{KeyTag: uint16(keyTag), Algorithm: uint8(algorithm), Digest: slot.Digest})
+	}
+
+	return z, true
+}
+
+// 添加或取代使用 PChome DNS 的網域，轉址記錄沒有標題。
+func (s *Server) SetHosts(name string, records []pchome.HostRecord) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	v := &zone{hosted: true}
+	for _, r := range records {
+		v.hosts = append(v.hosts, hostSlot{Name: r.Name, Type: strings.ToLower(r.Type), Content: r.Content})
+	}
+	s.zones[name] = v
+}
+
+// 取得網域目前的代管記錄，網域不存在或使用自管 DNS 時 ok 為 false。
+func (s *Server) Hosts(name string) ([]pchome.HostRecord, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	v, ok := s.zones[name]
+	if !ok || !v.hosted {
+		return nil, false
+	}
+
+	records := make([]pchome.HostRecord, 0)
+	for _, slot := range v.hosts {
+		if len(slot.Content) == 0 {
+			continue
+		}
+		records = append(records, pchome.HostRecord{Name: slot.Name, Type: strings.ToUpper(slot.Type), Content: slot.Content})
+	}
+
+	return records, true
+}
+
+// 設定子網域轉址記錄的網頁標題。
+func (s *Server) SetTitle(name, host, title string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if v, ok := s.zones[name]; ok {
+		for i := range v.hosts {
+			if v.hosts[i].Name == host && v.hosts[i].Type == "fwd" {
+				v.hosts[i].Title = title
+			}
+		}
+	}
+}
+
+// 取得子網域轉址記錄的網頁標題。
+func (s *Server) Title(name, host string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if v, ok := s.zones[name]; ok {
+		for _, slot := range v.hosts {
+			if slot.Name == host && slot.Type == "fwd" && len(slot.Content) > 0 {
+				return slot.Title
+			}
+		}
+	}
+
+	return ""
+}
+
+// 移除網域，之後的請求會回應錯誤網頁。
+func (s *Server) RemoveZone(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.zones, name)
+}
+
+// 取得收到的表單提交次數。
+func (s *Server) Posts() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.posts
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if r.URL.Path == "/manage/index.htm" {
+		names := make([]string, 0, len(s.zones))
+		for name := range s.zones {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		pages.ExecuteTemplate(w, "index", names)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		pages.ExecuteTemplate(w, "error", "表單格式錯誤。")
+		return
+	}
+	name := r.Form.Get("dn")
+	v, ok := s.zones[name]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		pages.ExecuteTemplate(w, "error", "查無此網域。")
+		return
+	}
+
+	switch r.Method + " " + r.URL.Path {
+	case "GET /manage/dns_edit.htm":
+	case "POST /manage/dns_edit.php":
+		s.posts++
+		v.hosted = r.PostForm.Get("dns_mode") == "0"
+		v.ns = v.ns[:0]
+		for i := 0; i < 5; i++ {
+			n := strconv.Itoa(i)
+			v.ns = append(v.ns, nsSlot{r.PostForm.Get("host_dn" + n), r.PostForm.Get("host_ip" + n), r.PostForm.Get("host_ipv6" + n)})
+		}
+		v.hosts = v.hosts[:0]
+		for i := 0; i < 10; i++ {
+			n := strconv.Itoa(i)
+			v.hosts = append(v.hosts, hostSlot {
+				Name: r.PostForm.Get("subhostf" + n),
+				Type: r.PostForm.Get("typef" + n),
+				Content: r.PostForm.Get("contentf" + n),
+				Title: r.PostForm.Get("fwd_titlef" + n),
+				Meta: r.PostForm.Get("fwd_meta_tagf" + n),
+				Description: r.PostForm.Get("fwd_description_tagf" + n),
+			})
+		}
+	case "GET /manage/set_dnssec.htm":
+		s.dnssecPage(w, name, v)
+		return
+	case "POST /manage/set_dnssec.php":
+		s.posts++
+		v.dnssec = v.dnssec[:0]
+		for i := 0; i < 5; i++ {
+			n := strconv.Itoa(i)
+			if digest := r.PostForm.Get("DS" + n); len(digest) > 0 {
+				v.dnssec = append(v.dnssec, dnssecSlot{r.PostForm.Get("KeyTag" + n), r.PostForm.Get("alg" + n), digest})
+			}
+		}
+		s.dnssecPage(w, name, v)
+		return
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		pages.ExecuteTemplate(w, "error", "找不到網頁。")
+		return
+	}
+
+	// NS 和代管記錄固定顯示 5 列和 10 列。
+	ns := append([]nsSlot{}, v.ns...)
+	for len(ns) < 5 {
+		ns = append(ns, nsSlot{})
+	}
+	hosts := append([]hostSlot{}, v.hosts...)
+	for len(hosts) < 10 {
+		hosts = append(hosts, hostSlot{Type: "fwd"})
+	}
+	pages.ExecuteTemplate(w, "dns", map[string]interface{} {
+		"Name": name,
+		"Hosted": v.hosted,
+		"NS": ns,
+		"Hosts": hosts,
+	})
+}
+
+// DNSSEC 記錄固定顯示 5 列。
+func (s *Server) dnssecPage(w http.ResponseWriter, name string, v *zone) {
+	records := append([]dnssecSlot{}, v.dnssec...)
+	for len(records) < 5 {
+		records = append(records, dnssecSlot{})
+	}
+	pages.ExecuteTemplate(w, "dnssec", map[string]interface{} {
+		"Name": name,
+		"DNSSEC": records,
+	})
+}
@@ -0,0 +1,365 @@
+package pchome
+
+import (
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 匯出和匯入的格式。
+const (
+	FormatCSV = "csv"
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+	FormatBIND = "bind"
+)
+
+// CSV 的欄位，每列為一筆 NS 或 DS 記錄。
+var portfolioHeader = []string{"zone", "type", "host", "glue", "key_tag", "algorithm", "digest_type", "digest", "updated_at"}
+
+// 匯出的 NS 主機。
+type PortfolioNS struct {
+	Host string `json:"host" yaml:"host"`
+	Glue string `json:"glue,omitempty" yaml:"glue,omitempty"`
+}
+
+// 匯出的 DS 記錄，digest type 依 digest 長度推測。
+type PortfolioDS struct {
+	KeyTag uint16 `json:"key_tag" yaml:"key_tag"`
+	Algorithm uint8 `json:"algorithm" yaml:"algorithm"`
+	DigestType int `json:"digest_type" yaml:"digest_type"`
+	Digest string `json:"digest" yaml:"digest"`
+}
+
+// 匯出的 zone，匯入時忽略 UpdatedAt。
+type PortfolioZone struct {
+	Zone string `json:"zone" yaml:"zone"`
+	NS []PortfolioNS `json:"ns" yaml:"ns"`
+	DS []PortfolioDS `json:"ds" yaml:"ds"`
+	UpdatedAt time.Time `json:"updated_at,omitempty" yaml:"updated_at,omitempty"`
+}
+
+// 所有 zone 的委派，依 zone 名稱排序。
+type Portfolio []PortfolioZone
+
+// 由組態的 zone 產生 portfolio。
+func NewPortfolio(zones map[string]Zone) Portfolio {
+	p := make(Portfolio, 0, len(zones))
+	for name, zone := range zones {
+		pz := PortfolioZone {
+			Zone: name,
+			NS: make([]PortfolioNS, 0, len(zone.NS)),
+			DS: make([]PortfolioDS, 0, len(zone.DNSSEC)),
+		}
+		for host, ip := range zone.NS {
+			pz.NS = append(pz.NS, PortfolioNS{Host: host, Glue: ip})
+		}
+		sort.Slice(pz.NS, func(i, j int) bool { return pz.NS[i].Host < pz.NS[j].Host })
+		for _, r := range zone.DNSSEC {
+			pz.DS = append(pz.DS, PortfolioDS{KeyTag: r.KeyTag, Algorithm: r.Algorithm, DigestType: DigestType(r.Digest), Digest: r.Digest})
+		}
+		if zone.UpdatedAt > 0 {
+			pz.UpdatedAt = time.Unix(zone.UpdatedAt, 0).UTC()
+		}
+		p = append(p, pz)
+	}
+	sort.Slice(p, func(i, j int) bool { return p[i].Zone < p[j].Zone })
+
+	return p
+}
+
+// 轉成 zone 的期望狀態。沒有 NS 時 NS 為 nil，表示不管理 NS；沒有 DS 表示全部移除。
+func (p Portfolio) Zones() map[string]Zone {
+	zones := make(map[string]Zone, len(p))
+	for _, pz := range p {
+		zone := Zone{DNSSEC: make([]DNSSEC, 0, len(pz.DS))}
+		if len(pz.NS) > 0 {
+			zone.NS = make(NS)
+		}
+		for _, ns := range pz.NS {
+			zone.NS[ns.Host] = ns.Glue
+		}
+		for _, ds := range pz.DS {
+			zone.DNSSEC = append(zone.DNSSEC, DNSSEC{KeyTag: ds.KeyTag, Algorithm: ds.Algorithm, Digest: ds.Digest})
+		}
+		zones[pz.Zone] = zone
+	}
+
+	return zones
+}
+
+// 以 current 補上檔案沒有的部分，回傳可以和 current 比較的期望狀態。
+// 沒有 NS 的 zone 沿用目前的 NS。不在 current 的 zone 不會改變。
+func (p Portfolio) Desired(current map[string]Zone) map[string]Zone {
+	zones := p.Zones()
+	for name, zone := range zones {
+		cur, ok := current[name]
+		if !ok {
+			continue
+		}
+		if zone.NS == nil {
+			zone.NS = cur.NS
+		}
+		zones[name] = zone
+	}
+
+	return zones
+}
+
+// 檢查所有 zone，回傳所有問題。每個 zone 最多 5 個 NS，沒有 NS 表示不管理 NS；最多 5 個 DS，
+// DS 的 digest 必須是十六進位且 digest type 和長度相符。
+func (p Portfolio) Validate() error {
+	msgs := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, pz := range p {
+		if !ValidHost(pz.Zone) || !strings.Contains(pz.Zone, ".") {
+			msgs = append(msgs, "bad zone name " + strconv.Quote(pz.Zone))
+			continue
+		}
+		if seen[pz.Zone] {
+			msgs = append(msgs, pz.Zone + ": duplicated zone")
+		}
+		seen[pz.Zone] = true
+
+		if len(pz.NS) > 5 {
+			msgs = append(msgs, pz.Zone + ": more than 5 NS records")
+		}
+		hosts := make(map[string]bool)
+		for _, ns := range pz.NS {
+			switch {
+			case !ValidHost(ns.Host):
+				msgs = append(msgs, pz.Zone + ": bad host name " + strconv.Quote(ns.Host))
+			case hosts[ns.Host]:
+				msgs = append(msgs, pz.Zone + ": duplicated host " + ns.Host)
+			}
+			hosts[ns.Host] = true
+			if !ValidGlue(ns.Glue) {
+				msgs = append(msgs, pz.Zone + ": bad glue " + strconv.Quote(ns.Glue) + " of " + ns.Host)
+			}
+		}
+
+		if len(pz.DS) > 5 {
+			msgs = append(msgs, pz.Zone + ": more than 5 DS records")
+		}
+		for _, ds := range pz.DS {
+			if _, err := hex.DecodeString(ds.Digest); err != nil || len(ds.Digest) == 0 {
+				msgs = append(msgs, pz.Zone + ": bad DS digest " + strconv.Quote(ds.Digest))
+			} else if ds.DigestType != DigestType(ds.Digest) {
+				msgs = append(msgs, pz.Zone + ": DS digest type " + strconv.Itoa(ds.DigestType) + " does not match the digest length")
+			}
+		}
+	}
+
+	if len(msgs) > 0 {
+		return errors.New(strings.Join(msgs, "\n"))
+	}
+	return nil
+}
+
+// 依副檔名推測格式，.zone 和 .db 為 BIND，無法判斷時回傳空字串。
+func FormatOf(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return FormatCSV
+	case ".json":
+		return FormatJSON
+	case ".yaml", ".yml":
+		return FormatYAML
+	case ".zone", ".db":
+		return FormatBIND
+	}
+
+	return ""
+}
+
+// 以 format 寫出 portfolio。
+func WritePortfolio(w io.Writer, p Portfolio, format string) error {
+	switch format {
+	case FormatCSV:
+		return writePortfolioCSV(w, p)
+	case FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", " ")
+		return encoder.Encode(p)
+	case FormatYAML:
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(p); err != nil {
+			return err
+		}
+		return encoder.Close()
+	case FormatBIND:
+		zones := p.Zones()
+		for _, pz := range p {
+			zone := zones[pz.Zone]
+			if !pz.UpdatedAt.IsZero() {
+				zone.UpdatedAt = pz.UpdatedAt.Unix()
+			}
+			zones[pz.Zone] = zone
+		}
+		return WriteBIND(w, zones)
+	}
+
+	return errors.New("Unknown format " + format + ", want csv, json, yaml or bind.")
+}
+
+// 以 format 讀取 portfolio，zone 和主機名稱轉成小寫，digest 轉成大寫。
+func ReadPortfolio(r io.Reader, format string) (Portfolio, error) {
+	var p Portfolio
+	switch format {
+	case FormatCSV:
+		var err error
+		if p, err = readPortfolioCSV(r); err != nil {
+			return nil, err
+		}
+	case FormatJSON:
+		decoder := json.NewDecoder(r)
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&p); err != nil {
+			return nil, errors.New("Bad JSON, " + err.Error() + ".")
+		}
+	case FormatYAML:
+		decoder := yaml.NewDecoder(r)
+		decoder.KnownFields(true)
+		if err := decoder.Decode(&p); err != nil && err != io.EOF {
+			return nil, errors.New("Bad YAML, " + err.Error() + ".")
+		}
+	case FormatBIND:
+		zones, err := ReadBIND(r)
+		if err != nil {
+			return nil, err
+		}
+		p = NewPortfolio(zones)
+	default:
+		return nil, errors.New("Unknown format " + format + ", want csv, json, yaml or bind.")
+	}
+
+	for i := range p {
+		p[i].Zone = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(p[i].Zone), "."))
+		for j := range p[i].NS {
+			p[i].NS[j].Host = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(p[i].NS[j].Host), "."))
+			p[i].NS[j].Glue = strings.TrimSpace(p[i].NS[j].Glue)
+		}
+		for j := range p[i].DS {
+			p[i].DS[j].Digest = strings.ToUpper(strings.TrimSpace(p[i].DS[j].Digest))
+		}
+	}
+	sort.SliceStable(p, func(i, j int) bool { return p[i].Zone < p[j].Zone })
+
+	return p, nil
+}
+
+// 每筆 NS 和 DS 一列，沒有記錄的 zone 輸出只有 zone 的一列。
+func writePortfolioCSV(w io.Writer, p Portfolio) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(portfolioHeader); err != nil {
+		return err
+	}
+
+	for _, pz := range p {
+		updated := ""
+		if !pz.UpdatedAt.IsZero() {
+			updated = pz.UpdatedAt.UTC().Format(time.RFC3339)
+		}
+		if len(pz.NS) == 0 && len(pz.DS) == 0 {
+			cw.Write([]string{pz.Zone, "", "", "", "", "", "", "", updated})
+		}
+		for _, ns := range pz.NS {
+			cw.Write([]string{pz.Zone, "NS", ns.Host, ns.Glue, "", "", "", "", updated})
+		}
+		for _, ds := range pz.DS {
+			cw.Write([]string{pz.Zone, "DS", "", "", strconv.Itoa(int(ds.KeyTag)), strconv.Itoa(int(ds.Algorithm)), strconv.Itoa(ds.DigestType), ds.Digest, updated})
+		}
+	}
+	cw.Flush()
+
+	return cw.Error()
+}
+
+// 讀取 writePortfolioCSV 的格式，欄位依標題對應，順序可以不同。
+func readPortfolioCSV(r io.Reader) (Portfolio, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	rows, err := cr.ReadAll()
+	if err != nil {
+		return nil, errors.New("Bad CSV, " + err.Error() + ".")
+	}
+	if len(rows) == 0 {
+		return Portfolio{}, nil
+	}
+
+	index := make(map[string]int)
+	for i, name := range rows[0] {
+		index[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"zone", "type"} {
+		if _, ok := index[name]; !ok {
+			return nil, errors.New("Bad CSV, no " + name + " column.")
+		}
+	}
+	field := func(row []string, name string) string {
+		if i, ok := index[name]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+
+	p := make(Portfolio, 0)
+	zones := make(map[string]int)
+	for n, row := range rows[1:] {
+		line := "Line " + strconv.Itoa(n + 2) + ": "
+		name := strings.ToLower(strings.TrimSuffix(field(row, "zone"), "."))
+		if len(name) == 0 {
+			continue
+		}
+		i, ok := zones[name]
+		if !ok {
+			i = len(p)
+			zones[name] = i
+			p = append(p, PortfolioZone{Zone: name, NS: make([]PortfolioNS, 0), DS: make([]PortfolioDS, 0)})
+		}
+		if v := field(row, "updated_at"); len(v) > 0 && p[i].UpdatedAt.IsZero() {
+			updated, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return nil, errors.New(line + "bad updated_at " + strconv.Quote(v) + ".")
+			}
+			p[i].UpdatedAt = updated
+		}
+
+		switch strings.ToUpper(field(row, "type")) {
+		case "":
+		case "NS":
+			p[i].NS = append(p[i].NS, PortfolioNS{Host: field(row, "host"), Glue: field(row, "glue")})
+		case "DS":
+			keyTag, err := strconv.ParseUint(field(row, "key_tag"), 10, 16)
+			if err != nil {
+				return nil, errors.New(line + "bad key tag " + strconv.Quote(field(row, "key_tag")) + ".")
+			}
+			algorithm, err := strconv.ParseUint(field(row, "algorithm"), 10, 8)
+			if err != nil {
+				return nil, errors.New(line + "bad algorithm " + strconv.Quote(field(row, "algorithm")) + ".")
+			}
+			digest := field(row, "digest")
+			digestType := DigestType(digest)
+			if v := field(row, "digest_type"); len(v) > 0 {
+				if digestType, err = strconv.Atoi(v); err != nil {
+					return nil, errors.New(line + "bad digest type " + strconv.Quote(v) + ".")
+				}
+			}
+			p[i].DS = append(p[i].DS, PortfolioDS{KeyTag: uint16(keyTag), Algorithm: uint8(algorithm), DigestType: digestType, Digest: digest})
+		default:
+			return nil, errors.New(line + "unknown type " + strconv.Quote(field(row, "type")) + ", want NS or DS.")
+		}
+	}
+
+	return p, nil
+}
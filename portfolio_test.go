@@ -0,0 +1,119 @@
+package pchome
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPortfolio(t *testing.T) {
+	zones := map[string]Zone {
+		"example.com": {
+			NS: NS{"ns1.example.com": "192.0.2.1", "ns2.example.net": ""},
+			DNSSEC: []DNSSEC{{KeyTag: 2371, Algorithm: 13, Digest: strings.Repeat("AB", 32)}},
+			UpdatedAt: 1700000000,
+		},
+		"example.org": {NS: NS{"ns1.example.net": ""}, DNSSEC: []DNSSEC{}},
+	}
+	p := NewPortfolio(zones)
+	if err := p.Validate(); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for _, format := range []string{FormatCSV, FormatJSON, FormatYAML, FormatBIND} {
+		var buf bytes.Buffer
+		if err := WritePortfolio(&buf, p, format); err != nil {
+			t.Fatal(err.Error())
+		}
+		read, err := ReadPortfolio(&buf, format)
+		if err != nil {
+			t.Fatalf("Reading %s: %s", format, err.Error())
+		}
+
+		got := read.Zones()
+		want := p.Zones()
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Read %s %+v, want %+v.", format, got, want)
+		}
+		if format != FormatBIND && !read[0].UpdatedAt.Equal(p[0].UpdatedAt) {
+			t.Errorf("Read %s updated at %v.", format, read[0].UpdatedAt)
+		}
+	}
+}
+
+func TestPortfolioCSV(t *testing.T) {
+	csv := "type,zone,host,glue,key_tag,algorithm,digest,digest_type\n" +
+		"NS,Example.COM.,NS1.example.com,192.0.2.1,,,,\n" +
+		"DS,example.com,,,2371,13," + strings.Repeat("ab", 20) + ",\n" +
+		"MX,example.com,mail.example.com,,,,,\n"
+
+	if _, err := ReadPortfolio(strings.NewReader(csv), FormatCSV); err == nil || !strings.Contains(err.Error(), "Line 4") {
+		t.Errorf("Reading an unknown type got %v.", err)
+	}
+
+	p, err := ReadPortfolio(strings.NewReader(strings.TrimSuffix(csv, "MX,example.com,mail.example.com,,,,,\n")), FormatCSV)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(p) != 1 || p[0].Zone != "example.com" || p[0].NS[0].Host != "ns1.example.com" || p[0].DS[0].DigestType != 1 || p[0].DS[0].Digest != strings.Repeat("AB", 20) {
+		t.Errorf("Read %+v.", p)
+	}
+}
+
+func TestPortfolioValidate(t *testing.T) {
+	p := Portfolio {
+		{Zone: "example.com", NS: []PortfolioNS{{Host: "bad_host"}, {Host: "ns1.example.net", Glue: "300.0.0.1"}}},
+		{Zone: "example.org", DS: []PortfolioDS{{KeyTag: 1, Algorithm: 13, DigestType: 1, Digest: strings.Repeat("A", 64)}}},
+		{Zone: "example.net", NS: []PortfolioNS{{Host: "ns1.example.net"}, {Host: "ns2.example.net"}, {Host: "ns3.example.net"}, {Host: "ns4.example.net"}, {Host: "ns5.example.net"}, {Host: "ns6.example.net"}}},
+	}
+
+	err := p.Validate()
+	if err == nil {
+		t.Fatal("Validating a bad portfolio succeeds.")
+	}
+	for _, msg := range []string{"bad host name", "bad glue", "more than 5 NS", "does not match"} {
+		if !strings.Contains(err.Error(), msg) {
+			t.Errorf("Missing %q in %q.", msg, err.Error())
+		}
+	}
+}
+
+func TestPortfolioRoundTrip(t *testing.T) {
+	zones := map[string]Zone {
+		"example.com": {
+			NS: NS{"ns1.example.com": "192.0.2.1,2001:db8::1", "ns2.example.net": "192.0.2.2"},
+			DNSSEC: []DNSSEC{{KeyTag: 2371, Algorithm: 13, Digest: strings.Repeat("AB", 32)}},
+			UpdatedAt: 1700000000,
+		},
+		"example.org": {NS: NS{"ns1.example.net": ""}, DNSSEC: []DNSSEC{}},
+		// 沒有 NS 的 zone 匯出後可以再匯入，不會改變。
+		"example.net": {DNSSEC: []DNSSEC{}},
+	}
+
+	for _, format := range []string{FormatCSV, FormatJSON, FormatYAML, FormatBIND} {
+		var buf bytes.Buffer
+		if err := WritePortfolio(&buf, NewPortfolio(zones), format); err != nil {
+			t.Fatalf("%s: %s", format, err.Error())
+		}
+		p, err := ReadPortfolio(&buf, format)
+		if err != nil {
+			t.Fatalf("%s: %s", format, err.Error())
+		}
+		if err := p.Validate(); err != nil {
+			t.Errorf("%s: %s", format, err.Error())
+		}
+		for name, zone := range p.Desired(zones) {
+			// BIND 不含 zone 之外主機的 IP，匯入指令沿用組態裡的 IP。
+			if format == FormatBIND && name == "example.com" {
+				if zone.NS["ns2.example.net"] != "" {
+					t.Errorf("%s: %s reads glue %q outside the zone.", format, name, zone.NS["ns2.example.net"])
+				}
+				zone.NS["ns2.example.net"] = zones[name].NS["ns2.example.net"]
+			}
+			if changes := DiffZone(zones[name], zone); len(changes) > 0 {
+				t.Errorf("%s: %s changes %v.", format, name, changes)
+			}
+		}
+	}
+}
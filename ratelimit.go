@@ -0,0 +1,164 @@
+package pchome
+
+import (
+	"time"
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// 預設每秒請求數。
+const DefaultRate = 2.0
+
+// 預設可連續送出的請求數。
+const DefaultBurst = 4
+
+// 限速器狀態，可以匯出成監控指標。
+type LimiterStats struct {
+	// 每秒補充的令牌數。
+	Rate float64
+	// 令牌桶容量。
+	Burst int
+	// 目前可用的令牌數。
+	Tokens float64
+	// 需要等待令牌的請求數。
+	Waits int64
+	// 累計等待時間。
+	Waited time.Duration
+	// 因為 Retry-After 暫停到這個時間。
+	PausedUntil time.Time
+}
+
+// 令牌桶限速器，同一個 Service 建立的所有服務共用。
+type RateLimiter struct {
+	mu sync.Mutex
+	rate float64
+	burst int
+	tokens float64
+	last time.Time
+	pausedUntil time.Time
+	waits int64
+	waited time.Duration
+}
+
+// 取得每秒 rate 個請求、最多連續 burst 個請求的限速器。
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &RateLimiter {
+		rate: rate,
+		burst: burst,
+		tokens: float64(burst),
+		last: time.Now(),
+	}
+}
+
+// 調整速率和容量。
+func (rl *RateLimiter) SetRate(rate float64, burst int) {
+	if burst < 1 {
+		burst = 1
+	}
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.refill(time.Now())
+	rl.rate = rate
+	rl.burst = burst
+	if rl.tokens > float64(burst) {
+		rl.tokens = float64(burst)
+	}
+}
+
+// 依經過時間補充令牌，呼叫前需持有鎖。
+func (rl *RateLimiter) refill(now time.Time) {
+	if now.After(rl.last) {
+		rl.tokens += now.Sub(rl.last).Seconds() * rl.rate
+		if rl.tokens > float64(rl.burst) {
+			rl.tokens = float64(rl.burst)
+		}
+	}
+	rl.last = now
+}
+
+// 等待取得一個令牌，ctx 取消時提早返回錯誤。rate 為 0 時不限速。
+func (rl *RateLimiter) Wait(ctx context.Context) error {
+	rl.mu.Lock()
+	now := time.Now()
+	if rl.rate <= 0 && !rl.pausedUntil.After(now) {
+		rl.mu.Unlock()
+		return nil
+	}
+
+	rl.refill(now)
+	rl.tokens--
+	var d time.Duration
+	if rl.tokens < 0 && rl.rate > 0 {
+		d = time.Duration(-rl.tokens / rl.rate * float64(time.Second))
+	}
+	if paused := rl.pausedUntil.Sub(now); paused > d {
+		d = paused
+	}
+	if d > 0 {
+		rl.waits++
+		rl.waited += d
+	}
+	rl.mu.Unlock()
+
+	if d <= 0 {
+		return nil
+	}
+
+	return sleep(ctx, d)
+}
+
+// 暫停所有請求直到 until，用於伺服器要求的 Retry-After。
+func (rl *RateLimiter) Pause(until time.Time) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	if until.After(rl.pausedUntil) {
+		rl.pausedUntil = until
+	}
+}
+
+// 取得限速器狀態。
+func (rl *RateLimiter) Stats() LimiterStats {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.refill(time.Now())
+
+	return LimiterStats {
+		Rate: rl.rate,
+		Burst: rl.burst,
+		Tokens: rl.tokens,
+		Waits: rl.waits,
+		Waited: rl.waited,
+		PausedUntil: rl.pausedUntil,
+	}
+}
+
+// 解析 Retry-After 標頭，支援秒數和 HTTP 日期，沒有時回傳 0。
+func retryAfter(resp *http.Response) time.Duration {
+	if resp == nil {
+		return 0
+	}
+
+	v := resp.Header.Get("Retry-After")
+	if len(v) == 0 {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(v); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,50 @@
+package pchome
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	rl := NewRateLimiter(100, 2)
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		if err := rl.Wait(context.Background()); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	// 只檢查下限，執行較慢時令牌會先補充，等待的次數和時間不固定。
+	elapsed := time.Since(start)
+	if elapsed < 15 * time.Millisecond {
+		t.Errorf("4 requests with burst 2 at 100/s took %s, want at least 20ms.", elapsed)
+	}
+	if stats := rl.Stats(); stats.Burst != 2 || stats.Waits > 2 || stats.Waited > elapsed {
+		t.Errorf("Stats %+v after %s.", stats, elapsed)
+	}
+
+	rl.Pause(time.Now().Add(time.Hour))
+	ctx, cancel := context.WithTimeout(context.Background(), 10 * time.Millisecond)
+	defer cancel()
+	if err := rl.Wait(ctx); err == nil {
+		t.Error("Waiting on a paused limiter should time out.")
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	resp := &http.Response{Header: http.Header{}}
+	if d := retryAfter(resp); d != 0 {
+		t.Errorf("Missing Retry-After gives %s.", d)
+	}
+
+	resp.Header.Set("Retry-After", "120")
+	if d := retryAfter(resp); d != 2 * time.Minute {
+		t.Errorf("Retry-After 120 gives %s.", d)
+	}
+
+	resp.Header.Set("Retry-After", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	if d := retryAfter(resp); d < 59 * time.Minute || d > time.Hour {
+		t.Errorf("Retry-After date gives %s.", d)
+	}
+}
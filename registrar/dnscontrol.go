@@ -0,0 +1,99 @@
+package registrar
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+)
+
+// dnscontrol print-ir 輸出的 JSON。
+type dnscontrolIR struct {
+	Registrars []struct {
+		Name string `json:"name"`
+		Type string `json:"type"`
+	} `json:"registrars"`
+	Domains []struct {
+		Name string `json:"name"`
+		Registrar string `json:"registrar"`
+		Nameservers []struct {
+			Name string `json:"name"`
+		} `json:"nameservers"`
+		Records []dnscontrolRecord `json:"records"`
+	} `json:"domains"`
+}
+
+// dnscontrol 的記錄，DS 的欄位只用在 DS。
+type dnscontrolRecord struct {
+	Type string `json:"type"`
+	Name string `json:"name"`
+	Target string `json:"target"`
+	KeyTag int `json:"dskeytag"`
+	Algorithm int `json:"dsalgorithm"`
+	DigestType int `json:"dsdigesttype"`
+	Digest string `json:"dsdigest"`
+}
+
+// 讀取 dnscontrol print-ir 的 JSON，取得註冊商為 registrar 的網域委派，registrar 為空字串時取得所有網域。
+//
+// name server 取自 NAMESERVER 和 DNS provider 的 nameservers，沒有時取自 zone 本身的 NS 記錄，
+// glue IP 取自 zone 本身的 A 和 AAAA 記錄。zone 本身的 DS 記錄為上層的 DS，沒有 DS 記錄時不管理 DS。
+func ParseDNSControl(r io.Reader, registrar string) ([]Delegation, error) {
+	var ir dnscontrolIR
+	if err := json.NewDecoder(r).Decode(&ir); err != nil {
+		return nil, errors.New("Bad dnscontrol IR, " + err.Error() + ".")
+	}
+
+	found := len(registrar) == 0
+	for _, reg := range ir.Registrars {
+		if reg.Name == registrar {
+			found = true
+		}
+	}
+	if !found {
+		return nil, errors.New("No registrar " + registrar + " in the dnscontrol IR.")
+	}
+
+	delegations := make([]Delegation, 0)
+	for _, domain := range ir.Domains {
+		if len(registrar) > 0 && domain.Registrar != registrar {
+			continue
+		}
+		zone := strings.ToLower(strings.TrimSuffix(domain.Name, "."))
+
+		hosts := make([]string, 0)
+		for _, ns := range domain.Nameservers {
+			hosts = append(hosts, ns.Name)
+		}
+		addrs := make(map[string][]string)
+		d := Delegation{Zone: zone}
+		for _, record := range domain.Records {
+			name := strings.ToLower(record.Name)
+			switch record.Type {
+			case "A", "AAAA":
+				addrs[name] = append(addrs[name], record.Target)
+			case "NS":
+				if name == "@" && len(domain.Nameservers) == 0 {
+					hosts = append(hosts, record.Target)
+				}
+			case "DS":
+				if name != "@" {
+					continue
+				}
+				ds, err := dsRecord(zone, record.KeyTag, record.Algorithm, record.DigestType, strings.ToUpper(record.Digest))
+				if err != nil {
+					return nil, err
+				}
+				d.DNSSEC = append(d.DNSSEC, ds)
+			}
+		}
+		if len(hosts) > 0 {
+			d.NS = glue(zone, hosts, addrs)
+		}
+
+		delegations = append(delegations, d)
+	}
+	sortDelegations(delegations)
+
+	return delegations, nil
+}
@@ -0,0 +1,119 @@
+package registrar
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// octoDNS 的記錄，單一值用 value，多個值用 values。
+type octoRecord struct {
+	Type string `yaml:"type"`
+	Value yaml.Node `yaml:"value"`
+	Values []yaml.Node `yaml:"values"`
+}
+
+// octoDNS 的 DS 值。
+type octoDS struct {
+	KeyTag int `yaml:"key_tag"`
+	Algorithm int `yaml:"algorithm"`
+	DigestType int `yaml:"digest_type"`
+	Digest string `yaml:"digest"`
+}
+
+func (r octoRecord) values() []yaml.Node {
+	if r.Value.Kind != 0 {
+		return append([]yaml.Node{r.Value}, r.Values...)
+	}
+
+	return r.Values
+}
+
+// 讀取 octoDNS YAML zone 檔的委派。
+//
+// zone 本身 ('') 的 NS 記錄為委派的 name server，glue IP 取自 zone 本身的 A 和 AAAA 記錄，
+// DS 記錄為上層的 DS。沒有 NS 或 DS 記錄時不管理該項。
+func ParseOctoDNS(zone string, r io.Reader) (Delegation, error) {
+	zone = strings.ToLower(strings.TrimSuffix(zone, "."))
+	var doc map[string]yaml.Node
+	if err := yaml.NewDecoder(r).Decode(&doc); err != nil && err != io.EOF {
+		return Delegation{}, errors.New("Bad octoDNS zone " + zone + ", " + err.Error() + ".")
+	}
+
+	d := Delegation{Zone: zone}
+	hosts := make([]string, 0)
+	addrs := make(map[string][]string)
+	for name, node := range doc {
+		var records []octoRecord
+		if node.Kind == yaml.SequenceNode {
+			if err := node.Decode(&records); err != nil {
+				return Delegation{}, errors.New("Bad octoDNS record " + name + " in " + zone + ", " + err.Error() + ".")
+			}
+		} else {
+			var record octoRecord
+			if err := node.Decode(&record); err != nil {
+				return Delegation{}, errors.New("Bad octoDNS record " + name + " in " + zone + ", " + err.Error() + ".")
+			}
+			records = append(records, record)
+		}
+
+		name = strings.ToLower(name)
+		if len(name) == 0 {
+			name = "@"
+		}
+		for _, record := range records {
+			for _, value := range record.values() {
+				switch {
+				case record.Type == "A" || record.Type == "AAAA":
+					addrs[name] = append(addrs[name], value.Value)
+				case record.Type == "NS" && name == "@":
+					hosts = append(hosts, value.Value)
+				case record.Type == "DS" && name == "@":
+					var v octoDS
+					if err := value.Decode(&v); err != nil {
+						return Delegation{}, errors.New("Bad octoDNS DS in " + zone + ", " + err.Error() + ".")
+					}
+					ds, err := dsRecord(zone, v.KeyTag, v.Algorithm, v.DigestType, strings.ToUpper(v.Digest))
+					if err != nil {
+						return Delegation{}, err
+					}
+					d.DNSSEC = append(d.DNSSEC, ds)
+				}
+			}
+		}
+	}
+	if len(hosts) > 0 {
+		d.NS = glue(zone, hosts, addrs)
+	}
+
+	return d, nil
+}
+
+// 讀取 octoDNS YamlProvider 目錄裡所有 zone 檔的委派，檔名為 zone 名稱加上 .yaml。
+func ReadOctoDNSDir(dir string) ([]Delegation, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.yaml"))
+	if err != nil {
+		return nil, err
+	}
+
+	delegations := make([]Delegation, 0, len(paths))
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		d, err := ParseOctoDNS(strings.TrimSuffix(filepath.Base(path), ".yaml"), f)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		delegations = append(delegations, d)
+	}
+	sortDelegations(delegations)
+
+	return delegations, nil
+}
@@ -0,0 +1,215 @@
+// Package registrar 把 dnscontrol 和 octoDNS 的設定轉成 PChome 註冊商層級的委派，
+// 也就是 zone 委派給哪些 name server 和上層的 DS 記錄，讓委派和 DNS 記錄由同一份設定管理。
+//
+// 讀取設定後以 Corrections 比較網站上的記錄，回傳的修正和 dnscontrol 的 registrar correction 一樣，
+// 先列出 Msg 確認，再呼叫 F 透過 NSService 和 DNSSECService 提交。
+package registrar
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/a2n/pchome"
+)
+
+// 設定裡一個 zone 的委派。
+type Delegation struct {
+	Zone string
+	// 委派的 name server 和 glue IP，nil 表示不管理。
+	NS pchome.NS
+	// 上層的 DS 記錄，nil 表示不管理，空 slice 表示全部移除。
+	DNSSEC []pchome.DNSSEC
+}
+
+// 需要提交的修正。
+type Correction struct {
+	Zone string
+	Msg string
+	Changes []pchome.Change
+	// 提交修正。
+	F func() error
+}
+
+// 註冊商轉接器。
+type Registrar struct {
+	// 組態服務，zone 不在組態時會先同步。
+	ConfigService *pchome.ConfigService
+	// 取得已登入的服務，nil 時使用 ConfigService.NewService。
+	Service func() (*pchome.Service, error)
+	// 持有組態鎖執行 Correction.F，同步 zone 和提交記錄都會寫入組態。nil 時使用 pchome.WithLock。
+	Lock func(fn func() error) error
+}
+
+// 取得使用 cs 的轉接器。
+func NewRegistrar(cs *pchome.ConfigService) *Registrar {
+	return &Registrar {
+		ConfigService: cs,
+	}
+}
+
+func (r *Registrar) service() (*pchome.Service, error) {
+	if r.Service != nil {
+		return r.Service()
+	}
+
+	return r.ConfigService.NewService()
+}
+
+func (r *Registrar) lock(fn func() error) error {
+	if r.Lock != nil {
+		return r.Lock(fn)
+	}
+
+	return pchome.WithLock(fn)
+}
+
+// 比較委派和網站上的記錄，回傳需要提交的修正，沒有差異時回傳空 slice。
+// 設定沒有 glue IP 的主機沿用網站上的 glue。
+func (r *Registrar) Corrections(d Delegation) ([]*Correction, error) {
+	s, err := r.service()
+	if err != nil {
+		return nil, err
+	}
+	zone := strings.ToLower(strings.TrimSuffix(d.Zone, "."))
+	corrections := make([]*Correction, 0)
+
+	if d.NS != nil {
+		ns := s.NewNSService()
+		current, err := ns.List(zone)
+		if err != nil {
+			return nil, err
+		}
+		record := make(pchome.NS)
+		for host, ip := range d.NS {
+			if len(ip) == 0 {
+				ip = current[host]
+			}
+			record[host] = ip
+		}
+
+		changes := pchome.DiffZone(pchome.Zone{NS: current}, pchome.Zone{NS: record})
+		if len(changes) > 0 {
+			corrections = append(corrections, &Correction {
+				Zone: zone,
+				Msg: message("NS", changes),
+				Changes: changes,
+				F: func() error {
+					return r.lock(func() error {
+						if err := r.ensure(s, zone); err != nil {
+							return err
+						}
+						return ns.Set(zone, record)
+					})
+				},
+			})
+		}
+	}
+
+	if d.DNSSEC != nil {
+		ds := s.NewDNSSECService()
+		current, err := ds.List(zone)
+		if err != nil {
+			return nil, err
+		}
+		records := append([]pchome.DNSSEC{}, d.DNSSEC...)
+
+		changes := pchome.DiffZone(pchome.Zone{DNSSEC: current}, pchome.Zone{DNSSEC: records})
+		if len(changes) > 0 {
+			corrections = append(corrections, &Correction {
+				Zone: zone,
+				Msg: message("DS", changes),
+				Changes: changes,
+				F: func() error {
+					return r.lock(func() error {
+						if err := r.ensure(s, zone); err != nil {
+							return err
+						}
+						return ds.Set(zone, records)
+					})
+				},
+			})
+		}
+	}
+
+	return corrections, nil
+}
+
+// 修正的說明，例如「Update NS: +ns ns1.example.net, -ns ns0.example.net」。
+func message(kind string, changes []pchome.Change) string {
+	msgs := make([]string, 0, len(changes))
+	for _, change := range changes {
+		msgs = append(msgs, change.String())
+	}
+
+	return "Update " + kind + ": " + strings.Join(msgs, ", ")
+}
+
+// 確認 zone 已同步到組態，NSService 和 DNSSECService 需要組態裡的 zone。
+func (r *Registrar) ensure(s *pchome.Service, zone string) error {
+	config, err := r.ConfigService.Read()
+	if err != nil {
+		return err
+	}
+	if _, ok := config.Zones[zone]; ok {
+		return nil
+	}
+
+	zones, err := r.ConfigService.UpdateZonesByNames(s, []string{zone})
+	if err != nil {
+		return err
+	}
+	if _, ok := zones[zone]; !ok {
+		return errors.New("No zone " + zone + " in the PChome account.")
+	}
+
+	return nil
+}
+
+// 依 zone 名稱排序。
+func sortDelegations(delegations []Delegation) {
+	sort.Slice(delegations, func(i, j int) bool { return delegations[i].Zone < delegations[j].Zone })
+}
+
+// 由 zone 自己的 A 和 AAAA 記錄找出 zone 之內主機的 glue，各取第一個 IPv4 和 IPv6 位址。
+// addrs 的 key 為相對於 zone 的名稱，zone 本身為 @。
+func glue(zone string, hosts []string, addrs map[string][]string) pchome.NS {
+	record := make(pchome.NS)
+	for _, host := range hosts {
+		host = strings.ToLower(strings.TrimSuffix(host, "."))
+		record[host] = ""
+
+		name := ""
+		switch {
+		case host == zone:
+			name = "@"
+		case strings.HasSuffix(host, "." + zone):
+			name = strings.TrimSuffix(host, "." + zone)
+		default:
+			continue
+		}
+		ipv4, ipv6 := "", ""
+		for _, ip := range addrs[name] {
+			if strings.Contains(ip, ":") && len(ipv6) == 0 {
+				ipv6 = ip
+			} else if !strings.Contains(ip, ":") && len(ipv4) == 0 {
+				ipv4 = ip
+			}
+		}
+		record[host] = pchome.JoinGlue(ipv4, ipv6)
+	}
+
+	return record
+}
+
+// 檢查 DS 記錄，digest type 必須和 digest 長度相符。
+func dsRecord(zone string, keyTag, algorithm, typ int, digest string) (pchome.DNSSEC, error) {
+	if keyTag < 0 || keyTag > 65535 || algorithm < 0 || algorithm > 255 {
+		return pchome.DNSSEC{}, errors.New(zone + ": bad DS key tag or algorithm.")
+	}
+	if typ != pchome.DigestType(digest) {
+		return pchome.DNSSEC{}, errors.New(zone + ": DS digest type does not match the digest length.")
+	}
+
+	return pchome.DNSSEC{KeyTag: uint16(keyTag), Algorithm: uint8(algorithm), Digest: digest}, nil
+}
@@ -0,0 +1,122 @@
+package registrar
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/a2n/pchome"
+	"github.com/a2n/pchome/pchometest"
+)
+
+var digest = strings.Repeat("A", 64)
+
+func check(t *testing.T, delegations []Delegation) {
+	if len(delegations) != 2 {
+		t.Fatalf("Got %d delegations, want 2.", len(delegations))
+	}
+
+	com := delegations[0]
+	if com.Zone != "example.com" || len(com.NS) != 2 || com.NS["ns1.example.com"] != "192.0.2.1,2001:db8::1" || com.NS["ns2.example.net"] != "" {
+		t.Errorf("Got %+v.", com)
+	}
+	if len(com.DNSSEC) != 1 || com.DNSSEC[0] != (pchome.DNSSEC{KeyTag: 2371, Algorithm: 13, Digest: digest}) {
+		t.Errorf("Got DS %+v.", com.DNSSEC)
+	}
+
+	org := delegations[1]
+	if org.Zone != "example.org" || len(org.NS) != 1 || org.DNSSEC != nil {
+		t.Errorf("Got %+v.", org)
+	}
+	if _, ok := org.NS["ns1.example.net"]; !ok {
+		t.Errorf("Got NS %v.", org.NS)
+	}
+}
+
+func TestParseDNSControl(t *testing.T) {
+	f, err := os.Open("testdata/dnscontrol.json")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer f.Close()
+
+	delegations, err := ParseDNSControl(f, "pchome")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	check(t, delegations)
+
+	if _, err := ParseDNSControl(strings.NewReader(`{"registrars": []}`), "pchome"); err == nil {
+		t.Error("Parsing without the registrar succeeds.")
+	}
+}
+
+func TestReadOctoDNSDir(t *testing.T) {
+	delegations, err := ReadOctoDNSDir("testdata/octodns")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	check(t, delegations)
+
+	bad := "'':\n  type: DS\n  value: {key_tag: 1, algorithm: 13, digest_type: 1, digest: " + digest + "}\n"
+	if _, err := ParseOctoDNS("example.com", strings.NewReader(bad)); err == nil {
+		t.Error("Parsing a DS with a mismatched digest type succeeds.")
+	}
+}
+
+func TestCorrections(t *testing.T) {
+	srv := pchometest.NewServer()
+	defer srv.Close()
+	srv.SetZone("example.com", pchome.Zone{NS: pchome.NS{"ns1.example.com": "192.0.2.9", "ns0.example.net": ""}})
+
+	dir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Chdir(dir)
+
+	cs := pchome.NewConfigService()
+	if err := cs.Save(&pchome.Config{Zones: map[string]pchome.Zone{}}); err != nil {
+		t.Fatal(err.Error())
+	}
+	r := NewRegistrar(cs)
+	r.Service = func() (*pchome.Service, error) { return srv.Service(), nil }
+	locked := 0
+	r.Lock = func(fn func() error) error {
+		locked++
+		return fn()
+	}
+
+	d := Delegation {
+		Zone: "example.com.",
+		NS: pchome.NS{"ns1.example.com": "", "ns2.example.net": ""},
+		DNSSEC: []pchome.DNSSEC{{KeyTag: 2371, Algorithm: 13, Digest: digest}},
+	}
+	corrections, err := r.Corrections(d)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(corrections) != 2 || !strings.HasPrefix(corrections[0].Msg, "Update NS: ") || !strings.HasPrefix(corrections[1].Msg, "Update DS: ") {
+		t.Fatalf("Got %d corrections.", len(corrections))
+	}
+	for _, c := range corrections {
+		if err := c.F(); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	if locked != len(corrections) {
+		t.Errorf("Applied %d corrections with the lock held %d times.", len(corrections), locked)
+	}
+
+	z, _ := srv.Zone("example.com")
+	if len(z.NS) != 2 || z.NS["ns1.example.com"] != "192.0.2.9" || len(z.DNSSEC) != 1 {
+		t.Errorf("Zone is %+v.", z)
+	}
+	if corrections, err := r.Corrections(d); err != nil || len(corrections) != 0 {
+		t.Errorf("Got %d corrections after applying, %v.", len(corrections), err)
+	}
+}
@@ -0,0 +1,136 @@
+package pchome
+
+import (
+	"time"
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"net/http"
+)
+
+// 重試策略。
+type RetryPolicy struct {
+	// 最多嘗試次數，包含第一次，1 表示不重試。
+	Attempts int
+	// 第一次重試前的等待時間，之後每次加倍。
+	Backoff time.Duration
+	// 等待時間上限。
+	MaxBackoff time.Duration
+	// 等待時間隨機增減的比例，介於 0 和 1。
+	Jitter float64
+}
+
+// 預設的重試策略。
+var DefaultRetryPolicy = RetryPolicy {
+	Attempts: 3,
+	Backoff: 500 * time.Millisecond,
+	MaxBackoff: 10 * time.Second,
+	Jitter: 0.2,
+}
+
+// 取得重試策略，未設定的欄位使用預設值。
+func (s *Service) retryPolicy() RetryPolicy {
+	rp := s.Retry
+	if rp.Attempts <= 0 {
+		rp.Attempts = DefaultRetryPolicy.Attempts
+	}
+	if rp.Backoff <= 0 {
+		rp.Backoff = DefaultRetryPolicy.Backoff
+	}
+	if rp.MaxBackoff <= 0 {
+		rp.MaxBackoff = DefaultRetryPolicy.MaxBackoff
+	}
+	if rp.Jitter < 0 || rp.Jitter > 1 {
+		rp.Jitter = DefaultRetryPolicy.Jitter
+	}
+
+	return rp
+}
+
+// 第 n 次重試前的等待時間。
+func (rp RetryPolicy) backoff(n int) time.Duration {
+	d := rp.Backoff
+	for i := 1; i < n && d < rp.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > rp.MaxBackoff {
+		d = rp.MaxBackoff
+	}
+
+	if rp.Jitter > 0 {
+		d = time.Duration(float64(d) * (1 + rp.Jitter * (2 * rand.Float64() - 1)))
+	}
+
+	return d
+}
+
+// 是否為冪等的請求方法。
+func idempotent(method string) bool {
+	switch method {
+	case "GET", "HEAD", "OPTIONS":
+		return true
+	}
+
+	return false
+}
+
+// 判斷請求是否可以重試。
+// 冪等請求在連線錯誤和暫時性的狀態碼時重試；
+// 其他請求只在還沒連上伺服器時重試，避免重複提交表單。
+func retryable(req *http.Request, resp *http.Response, err error) bool {
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return false
+		}
+
+		var opErr *net.OpError
+		if errors.As(err, &opErr) && opErr.Op == "dial" {
+			return true
+		}
+
+		return idempotent(req.Method)
+	}
+
+	if !idempotent(req.Method) {
+		return false
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+
+	return false
+}
+
+// 重新取得請求內容以便重試，無法重送內容時回傳 false。
+func rewind(req *http.Request) bool {
+	if req.Body == nil || req.Body == http.NoBody {
+		return true
+	}
+	if req.GetBody == nil {
+		return false
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return false
+	}
+	req.Body = body
+
+	return true
+}
+
+// 等待 d，請求取消時提早返回。
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
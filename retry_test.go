@@ -0,0 +1,117 @@
+package pchome
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRetry(t *testing.T) {
+	hits := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits[r.Method]++
+		if hits[r.Method] < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	s := NewService("key")
+	s.Retry = RetryPolicy{Attempts: 3, Backoff: time.Millisecond, MaxBackoff: time.Millisecond}
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	resp, err := s.Do(req)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || hits["GET"] != 3 {
+		t.Errorf("GET status %d after %d attempts, want 200 after 3.", resp.StatusCode, hits["GET"])
+	}
+
+	req, _ = http.NewRequest("POST", server.URL, strings.NewReader("dn=example.com"))
+	resp, err = s.Do(req)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable || hits["POST"] != 1 {
+		t.Errorf("POST status %d after %d attempts, want 503 after 1.", resp.StatusCode, hits["POST"])
+	}
+}
+
+func TestZoneListDoTransportError(t *testing.T) {
+	s := NewService("key")
+	s.Retry = RetryPolicy{Attempts: 2, Backoff: time.Millisecond}
+	s.Client = &http.Client{Transport: roundTripFunc(func(*http.Request) (*http.Response, error) {
+		return nil, http.ErrHandlerTimeout
+	})}
+
+	if _, err := s.NewZoneService().List().Do(); err == nil {
+		t.Error("Listing zones without a connection should fail.")
+	}
+}
+
+// 記錄請求路徑的觀察者。
+type requestObserver struct {
+	nopObserver
+	paths []string
+}
+
+func (o *requestObserver) ObserveRequest(endpoint string, status int, d time.Duration) {
+	o.paths = append(o.paths, endpoint)
+}
+
+// 登入、檢查 session 和登出也經過 Service.Do，共用限速、重試和觀察者。
+func TestSessionRequests(t *testing.T) {
+	dir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Chdir(dir)
+
+	o := &requestObserver{}
+	cs := NewConfigService()
+	cs.Observer = o
+	cs.Limiter = NewRateLimiter(0.001, 10)
+	cs.Client = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		resp := &http.Response {
+			StatusCode: http.StatusOK,
+			Header: http.Header{},
+			Body: http.NoBody,
+			Request: req,
+		}
+		if req.URL.Path == "/adm/person_sell.htm" {
+			resp.Header.Add("Set-Cookie", "loginkuser=key")
+		}
+		return resp, nil
+	})}
+
+	key, err := cs.DoGetKey("user@example.com", "password")
+	if err != nil || key != "key" {
+		t.Fatalf("Got key %q, %v.", key, err)
+	}
+	if !cs.Alive(key) {
+		t.Error("The session is not alive.")
+	}
+	cs.Service = cs.newService(key)
+	if err := cs.Logout(); err != nil {
+		t.Error(err.Error())
+	}
+
+	want := "/adm/person_sell.htm /manage/index.htm /adm/logout.php"
+	if got := strings.Join(o.paths, " "); got != want {
+		t.Errorf("Observed %q, want %q.", got, want)
+	}
+	if stats := cs.Limiter.Stats(); stats.Tokens > 7.5 {
+		t.Errorf("Limiter stats %+v, want 3 requests.", stats)
+	}
+}
@@ -0,0 +1,131 @@
+package pchome
+
+import (
+	"time"
+	"encoding/json"
+	"os"
+	"io/ioutil"
+	"net/http"
+	"errors"
+)
+
+// 預設的 session 快取檔案位置
+const DefaultSessionPath = ".pchome-session"
+
+// Session 結構，記錄 loginkuser 和取得時間。
+type Session struct {
+	Key string
+	ObtainedAt int64
+}
+
+// 讀取本地 session 快取。
+func (cs *ConfigService) ReadSession() (Session, error) {
+	b, err := ioutil.ReadFile(DefaultSessionPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return Session{}, nil
+		}
+		cs.log().Error("read session file failed", LogError, err)
+		return Session{}, errors.New("Read session file failed.")
+	}
+
+	var session Session
+	err = json.Unmarshal(b, &session)
+	if err != nil {
+		cs.log().Error("unmarshal json failed", LogError, err)
+		return Session{}, errors.New("Unmarshal session json failed.")
+	}
+
+	return session, nil
+}
+
+// 儲存 session 快取。
+func (cs *ConfigService) SaveSession(session *Session) error {
+	if session == nil {
+		cs.log().Error("has nil session")
+		return errors.New("nil session.")
+	}
+
+	b, err := json.Marshal(session)
+	if err != nil {
+		cs.log().Error("marshal json failed", LogError, err)
+		return errors.New("Marshal json failed.")
+	}
+
+	// 只有自己可讀寫，裡面是登入憑證。
+	err = ioutil.WriteFile(DefaultSessionPath, b, 0600)
+	if err != nil {
+		cs.log().Error("write session file failed", LogError, err)
+		return errors.New("Writing session file failed.")
+	}
+
+	return nil
+}
+
+// 移除 session 快取。
+func (cs *ConfigService) RemoveSession() error {
+	err := os.Remove(DefaultSessionPath)
+	if err != nil && !os.IsNotExist(err) {
+		cs.log().Error("remove the session file failed", LogError, err)
+		return errors.New("Failed to remove the session file.")
+	}
+
+	return nil
+}
+
+// 檢查存取鑰匙是否仍然有效。
+// 未登入的請求會被導向登入頁，所以不跟隨轉址，只看回應狀態。
+func (cs *ConfigService) Alive(key string) bool {
+	if len(key) == 0 {
+		return false
+	}
+
+	req, err := http.NewRequest("GET", ENDPOINT + "/index.htm", nil)
+	if err != nil {
+		cs.log().Error("creates request failed", LogError, err)
+		return false
+	}
+	s := cs.newService(key)
+	s.SetCookie(req)
+
+	client := *cs.client()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	if client.Timeout == 0 {
+		client.Timeout = 30 * time.Second
+	}
+	s.Client = &client
+	resp, err := s.Do(req)
+	if err != nil {
+		cs.log().Error("requesting failed", LogError, err)
+		return false
+	}
+	resp.Body.Close()
+
+	return resp.StatusCode == http.StatusOK
+}
+
+// 登入並快取取得的存取鑰匙。
+func (cs *ConfigService) login(email, password string) (string, error) {
+	key, err := cs.DoGetKey(email, password)
+	if err == nil && len(key) == 0 {
+		cs.log().Error("gets an empty key", LogOperation, "login")
+		err = errors.New("Your email or password is wrong.")
+	}
+	cs.observer().ObserveLogin(err)
+	if err != nil {
+		return "", err
+	}
+
+	session := Session {
+		Key: key,
+		ObtainedAt: time.Now().Unix(),
+	}
+	if err = cs.SaveSession(&session); err != nil {
+		return "", err
+	}
+	cs.log().Info("logged in", LogOperation, "login")
+
+	return key, nil
+}
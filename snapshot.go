@@ -0,0 +1,261 @@
+package pchome
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 預設的快照目錄。
+const DefaultSnapshotDir = ".pchome-snapshots"
+
+// 預設保留的快照數量，超過時刪除最舊的快照。
+const DefaultSnapshotKeep = 200
+
+// 這段時間內的快照不論數量一律保留，一次批次或匯入超過 DefaultSnapshotKeep 個 zone 時，
+// 批次之前的快照才不會被刪除，rollback 仍然可以還原。
+const DefaultSnapshotRetain = 24 * time.Hour
+
+// 快照編號的時間格式，依字串排序即依時間排序。
+const snapshotIDFormat = "20060102T150405.000000000Z"
+
+// 快照，記錄一次異動前所有 zone 的內容。
+type Snapshot struct {
+	ID string
+	Time time.Time
+	// 快照之後的異動名稱和 zone。
+	Operation string
+	Zone string
+	Zones map[string]Zone
+}
+
+// 保護快照目錄，避免並行異動取得相同編號。
+var snapshotMu sync.Mutex
+
+// 快照檔案的路徑。
+func snapshotPath(id string) string {
+	return filepath.Join(DefaultSnapshotDir, id + ".json")
+}
+
+// 在 op 異動 zone 前，把本地組態的所有 zone 存成快照，回傳快照編號。
+func (cs *ConfigService) takeSnapshot(op, zone string) (string, error) {
+	config, err := cs.Read()
+	if err != nil {
+		return "", err
+	}
+
+	snapshotMu.Lock()
+	defer snapshotMu.Unlock()
+
+	if err := os.MkdirAll(DefaultSnapshotDir, 0700); err != nil {
+		cs.log().Error("creates snapshot directory failed", LogError, err)
+		return "", errors.New("Creating snapshot directory failed.")
+	}
+
+	now := time.Now().UTC()
+	id := now.Format(snapshotIDFormat)
+	for {
+		if _, err := os.Stat(snapshotPath(id)); os.IsNotExist(err) {
+			break
+		}
+		now = now.Add(time.Nanosecond)
+		id = now.Format(snapshotIDFormat)
+	}
+
+	snapshot := Snapshot {
+		ID: id,
+		Time: now,
+		Operation: op,
+		Zone: zone,
+		Zones: config.Zones,
+	}
+	b, err := json.Marshal(snapshot)
+	if err != nil {
+		cs.log().Error("marshal json failed", LogError, err)
+		return "", errors.New("Marshal json failed.")
+	}
+	if err := ioutil.WriteFile(snapshotPath(id), b, 0600); err != nil {
+		cs.log().Error("write snapshot file failed", LogError, err)
+		return "", errors.New("Writing snapshot file failed.")
+	}
+	cs.log().Debug("took snapshot", "snapshot", id, LogOperation, op, LogZone, zone)
+
+	cs.pruneSnapshots()
+	return id, nil
+}
+
+// 刪除超過保留數量且超過 DefaultSnapshotRetain 的舊快照。
+func (cs *ConfigService) pruneSnapshots() {
+	ids, err := snapshotIDs()
+	if err != nil {
+		return
+	}
+
+	retain := time.Now().UTC().Add(-DefaultSnapshotRetain).Format(snapshotIDFormat)
+	for len(ids) > DefaultSnapshotKeep && ids[0] < retain {
+		if err := os.Remove(snapshotPath(ids[0])); err != nil {
+			cs.log().Warn("remove snapshot file failed", "snapshot", ids[0], LogError, err)
+		}
+		ids = ids[1:]
+	}
+}
+
+// 依時間先後列出快照編號。
+func snapshotIDs() ([]string, error) {
+	files, err := ioutil.ReadDir(DefaultSnapshotDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []string{}, nil
+		}
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(files))
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), ".json") {
+			continue
+		}
+		ids = append(ids, strings.TrimSuffix(f.Name(), ".json"))
+	}
+	sort.Strings(ids)
+
+	return ids, nil
+}
+
+// 依時間先後列出所有快照。
+func (cs *ConfigService) Snapshots() ([]Snapshot, error) {
+	ids, err := snapshotIDs()
+	if err != nil {
+		cs.log().Error("read snapshot directory failed", LogError, err)
+		return nil, errors.New("Reading snapshot directory failed.")
+	}
+
+	snapshots := make([]Snapshot, 0, len(ids))
+	for _, id := range ids {
+		snapshot, err := cs.ReadSnapshot(id)
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+
+	return snapshots, nil
+}
+
+// 讀取快照。
+func (cs *ConfigService) ReadSnapshot(id string) (Snapshot, error) {
+	if len(id) == 0 || strings.ContainsAny(id, `/\`) {
+		return Snapshot{}, errors.New("Bad snapshot id " + id + ".")
+	}
+
+	b, err := ioutil.ReadFile(snapshotPath(id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return Snapshot{}, errors.New("No such snapshot " + id + ".")
+		}
+		cs.log().Error("read snapshot file failed", "snapshot", id, LogError, err)
+		return Snapshot{}, errors.New("Reading snapshot file failed.")
+	}
+
+	var snapshot Snapshot
+	if err := json.Unmarshal(b, &snapshot); err != nil {
+		cs.log().Error("unmarshal json failed", "snapshot", id, LogError, err)
+		return Snapshot{}, errors.New("Unmarshal snapshot json failed.")
+	}
+
+	return snapshot, nil
+}
+
+// 比較網站上的記錄和快照，回傳每個有差異的 zone 要還原的變更。
+// zones 為空時比較本地組態和快照不同的 zone，以及快照之後異動的 zone。快照裡沒有 NS 的 zone 不比較 NS。
+func (cs *ConfigService) SnapshotDiff(s *Service, snapshot Snapshot, zones []string) (map[string][]Change, error) {
+	if len(zones) == 0 {
+		config, err := cs.Read()
+		if err != nil {
+			return nil, err
+		}
+		zones = changedZones(config.Zones, snapshot)
+	}
+
+	ns := s.NewNSService()
+	ds := s.NewDNSSECService()
+	diffs := make(map[string][]Change)
+	for _, zone := range zones {
+		want, ok := snapshot.Zones[zone]
+		if !ok {
+			return nil, errors.New("The snapshot has no zone " + zone + ".")
+		}
+
+		record, err := ns.List(zone)
+		if err != nil {
+			return nil, err
+		}
+		records, err := ds.List(zone)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(want.NS) == 0 {
+			want.NS = record
+		}
+		changes := DiffZone(Zone{NS: record, DNSSEC: records}, want)
+		if len(changes) > 0 {
+			diffs[zone] = changes
+		}
+	}
+
+	return diffs, nil
+}
+
+// 本地組態和快照不同的 zone，加上快照之後異動的 zone，依名稱排序。
+func changedZones(zones map[string]Zone, snapshot Snapshot) []string {
+	names := make([]string, 0)
+	for name, want := range snapshot.Zones {
+		if name == snapshot.Zone || len(DiffZone(zones[name], want)) > 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// 把 diffs 裡的 zone 還原成快照的 NS 和 DNSSEC 記錄，只提交有差異的種類。
+// 快照裡沒有 NS 的 zone 不管理 NS，不會提交空的委派。還原本身也是異動，會留下新的快照和稽核紀錄。
+func (cs *ConfigService) Restore(s *Service, snapshot Snapshot, diffs map[string][]Change) error {
+	names := make([]string, 0, len(diffs))
+	for name := range diffs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	ns := s.NewNSService()
+	ds := s.NewDNSSECService()
+	for _, name := range names {
+		want := snapshot.Zones[name]
+		kinds := make(map[string]bool)
+		for _, change := range diffs[name] {
+			kinds[change.Kind] = true
+		}
+
+		if kinds["ns"] && len(want.NS) > 0 {
+			if err := ns.Set(name, want.NS); err != nil {
+				return err
+			}
+		}
+		if kinds["dnssec"] {
+			if err := ds.Set(name, want.DNSSEC); err != nil {
+				return err
+			}
+		}
+		cs.log().Info("restored zone", LogOperation, "rollback", LogZone, name, "snapshot", snapshot.ID)
+	}
+
+	return nil
+}
@@ -0,0 +1,156 @@
+package pchome
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSnapshot(t *testing.T) {
+	dir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Chdir(dir)
+
+	cs := NewConfigService()
+	config := Config {
+		Zones: map[string]Zone {
+			"example.com": {NS: NS{"ns0.example.com": "10.0.0.0"}},
+			"example.net": {NS: NS{}},
+		},
+	}
+	if err := cs.Save(&config); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	first, err := cs.takeSnapshot("ns.add", "example.net")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	second, err := cs.takeSnapshot("ns.add", "example.net")
+	if err != nil || second <= first {
+		t.Fatalf("Snapshot ids %s and %s, %v.", first, second, err)
+	}
+
+	snapshots, err := cs.Snapshots()
+	if err != nil || len(snapshots) != 2 || snapshots[0].ID != first {
+		t.Fatalf("Snapshots %+v, %v.", snapshots, err)
+	}
+
+	snapshot, err := cs.ReadSnapshot(first)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if snapshot.Zones["example.com"].NS["ns0.example.com"] != "10.0.0.0" {
+		t.Errorf("Snapshot has zones %+v.", snapshot.Zones)
+	}
+
+	config.Zones["example.com"] = Zone{NS: NS{"ns0.example.com": "10.0.0.1"}}
+	if names := changedZones(config.Zones, snapshot); len(names) != 2 || names[0] != "example.com" || names[1] != "example.net" {
+		t.Errorf("Changed zones %v.", names)
+	}
+
+	if _, err := cs.ReadSnapshot("../.pchome"); err == nil {
+		t.Error("Reading a snapshot outside the directory should fail.")
+	}
+}
+
+func TestPruneSnapshots(t *testing.T) {
+	dir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Chdir(dir)
+
+	if err := os.MkdirAll(DefaultSnapshotDir, 0700); err != nil {
+		t.Fatal(err.Error())
+	}
+	write := func(at time.Time) string {
+		id := at.UTC().Format(snapshotIDFormat)
+		if err := ioutil.WriteFile(snapshotPath(id), []byte("{}"), 0600); err != nil {
+			t.Fatal(err.Error())
+		}
+		return id
+	}
+
+	// 兩天前的 10 個快照，和像是一次大批次在最近一小時留下的快照。
+	now := time.Now()
+	old := make([]string, 0)
+	for i := 0; i < 10; i++ {
+		old = append(old, write(now.Add(-48 * time.Hour + time.Duration(i) * time.Second)))
+	}
+	recent := make([]string, 0)
+	for i := 0; i < DefaultSnapshotKeep + 5; i++ {
+		recent = append(recent, write(now.Add(-time.Hour + time.Duration(i) * time.Second)))
+	}
+
+	NewConfigService().pruneSnapshots()
+	ids, err := snapshotIDs()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(ids) != len(recent) || ids[0] != recent[0] {
+		t.Errorf("Kept %d snapshots from %s, want the %d recent ones from %s.", len(ids), ids[0], len(recent), recent[0])
+	}
+}
+
+// 快照裡沒有 NS 的 zone 還原時不會提交空的委派。
+func TestRestoreWithoutNS(t *testing.T) {
+	dir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Chdir(dir)
+
+	current := Zone {
+		NS: NS{"ns1.example.net": ""},
+		DNSSEC: []DNSSEC{{KeyTag: 2371, Algorithm: 13, Digest: strings.Repeat("AB", 32)}},
+	}
+	cs := NewConfigService()
+	if err := cs.Save(&Config{Zones: map[string]Zone{"example.com": current}}); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	posts := make([]string, 0)
+	s := NewService("key")
+	s.Limiter = nil
+	s.Client = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		posts = append(posts, req.URL.Path)
+		return &http.Response {
+			StatusCode: http.StatusOK,
+			Header: http.Header{"Content-Type": []string{"text/html; charset=utf-8"}},
+			Body: http.NoBody,
+			Request: req,
+		}, nil
+	})}
+
+	snapshot := Snapshot{ID: "20240101T000000.000000000", Zones: map[string]Zone{"example.com": {DNSSEC: []DNSSEC{}}}}
+	diffs := map[string][]Change{"example.com": DiffZone(current, snapshot.Zones["example.com"])}
+	if err := cs.Restore(s, snapshot, diffs); err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(posts) != 1 || !strings.HasSuffix(posts[0], "/set_dnssec.php") {
+		t.Errorf("Posted %v, want only DNSSEC.", posts)
+	}
+
+	config, err := cs.Read()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if zone := config.Zones["example.com"]; len(zone.NS) != 1 || len(zone.DNSSEC) != 0 {
+		t.Errorf("Restored zone %+v.", zone)
+	}
+}
@@ -0,0 +1,138 @@
+package pchome
+
+import (
+	"time"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// 同步選項。所有 worker 的請求都經過 Service.Limiter 限速。
+type SyncOptions struct {
+	// 同時同步的 zone 數量。
+	Concurrency int
+	// 在這段時間內更新過的 zone 不重新同步，0 表示一律同步。
+	TTL time.Duration
+	// 每完成一個 zone 回報一次進度，可為 nil。
+	Progress func(SyncProgress)
+}
+
+// 預設的同步選項。
+var DefaultSyncOptions = SyncOptions {
+	Concurrency: 4,
+}
+
+// 同步進度。
+type SyncProgress struct {
+	Zone string
+	Done int
+	Total int
+	Err error
+}
+
+// 同步錯誤，記錄每個同步失敗的 zone。
+type SyncError struct {
+	Errors map[string]error
+}
+
+func (e *SyncError) Error() string {
+	names := make([]string, 0, len(e.Errors))
+	for name := range e.Errors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	msgs := make([]string, 0, len(names))
+	for _, name := range names {
+		msgs = append(msgs, name + ": " + e.Errors[name].Error())
+	}
+
+	return "Synchronizing zones failed, " + strings.Join(msgs, "; ") + "."
+}
+
+// 取得組態服務的同步選項，未設定的欄位使用預設值。
+func (cs *ConfigService) syncOptions() SyncOptions {
+	opt := cs.Sync
+	if opt.Concurrency <= 0 {
+		opt.Concurrency = DefaultSyncOptions.Concurrency
+	}
+
+	return opt
+}
+
+// 並行同步指定 zone 的 NS 和 DNSSEC 記錄。
+// 失敗的 zone 不會中斷其他 zone，而是彙整成 *SyncError 和成功的結果一起回傳。
+func (cs *ConfigService) syncZones(s *Service, names []string, opt SyncOptions) (map[string]Zone, error) {
+	// 所有 worker 共用 s 的限速器。
+	ns := s.NewNSService()
+	ds := s.NewDNSSECService()
+
+	jobs := make(chan string)
+	zones := make(map[string]Zone)
+	failed := make(map[string]error)
+	done := 0
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < opt.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for name := range jobs {
+				zone, err := syncZone(ns, ds, name)
+				cs.observer().ObserveZoneSynced(name, err)
+
+				mu.Lock()
+				if err != nil {
+					failed[name] = err
+				} else {
+					zones[name] = zone
+				}
+				done++
+				progress := SyncProgress {
+					Zone: name,
+					Done: done,
+					Total: len(names),
+					Err: err,
+				}
+				mu.Unlock()
+
+				cs.log().Debug("received dns records", LogZone, name, "done", progress.Done, "total", progress.Total)
+				if opt.Progress != nil {
+					opt.Progress(progress)
+				}
+			}
+		}()
+	}
+
+	for _, name := range names {
+		jobs <- name
+	}
+	close(jobs)
+	wg.Wait()
+
+	if len(failed) > 0 {
+		return zones, &SyncError{Errors: failed}
+	}
+
+	return zones, nil
+}
+
+// 同步單一 zone。
+func syncZone(ns *NSService, ds *DNSSECService, name string) (Zone, error) {
+	nsRecord, err := ns.List(name)
+	if err != nil {
+		return Zone{}, err
+	}
+
+	dnssecSlice, err := ds.List(name)
+	if err != nil {
+		return Zone{}, err
+	}
+
+	return Zone {
+		NS: nsRecord,
+		DNSSEC: dnssecSlice,
+		UpdatedAt: time.Now().Unix(),
+	}, nil
+}
@@ -0,0 +1,91 @@
+package pchome
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSyncZones(t *testing.T) {
+	raw := map[string][]byte {
+		"/manage/dns_edit.htm": fixture(t, "ns_full.html"),
+		"/manage/set_dnssec.htm": fixture(t, "dnssec_empty.html"),
+	}
+
+	var mu sync.Mutex
+	running, peak := 0, 0
+	s := NewService("key")
+	s.Limiter = nil
+	s.Client = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		mu.Lock()
+		running++
+		if running > peak {
+			peak = running
+		}
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+
+		status := http.StatusOK
+		if req.URL.Query().Get("dn") == "bad.example" {
+			status = http.StatusInternalServerError
+		}
+		return &http.Response {
+			StatusCode: status,
+			Header: http.Header{"Content-Type": []string{"text/html; charset=utf-8"}},
+			Body: ioutil.NopCloser(bytes.NewReader(raw[req.URL.Path])),
+			Request: req,
+		}, nil
+	})}
+
+	names := []string{"a.example", "b.example", "bad.example", "c.example", "d.example", "e.example"}
+	progress := make([]SyncProgress, 0)
+	opt := SyncOptions {
+		Concurrency: 2,
+		Progress: func(p SyncProgress) {
+			mu.Lock()
+			progress = append(progress, p)
+			mu.Unlock()
+		},
+	}
+
+	zones, err := NewConfigService().syncZones(s, names, opt)
+	syncErr, ok := err.(*SyncError)
+	if !ok || len(syncErr.Errors) != 1 || syncErr.Errors["bad.example"] == nil {
+		t.Fatalf("Got error %v, want a SyncError of bad.example.", err)
+	}
+	if len(zones) != 5 {
+		t.Errorf("Synchronized %d zones, want 5.", len(zones))
+	}
+	if _, ok := zones["bad.example"]; ok {
+		t.Error("The failed zone is in the result.")
+	}
+
+	if peak > 2 {
+		t.Errorf("%d requests at the same time, want at most 2.", peak)
+	}
+
+	if len(progress) != len(names) {
+		t.Fatalf("Progress called %d times, want %d.", len(progress), len(names))
+	}
+	// 回呼可能不依完成的順序呼叫，但每個進度只會出現一次。
+	failed := 0
+	seen := make(map[int]bool)
+	for _, p := range progress {
+		if p.Done < 1 || p.Done > len(names) || seen[p.Done] || p.Total != len(names) {
+			t.Errorf("Progress %d/%d.", p.Done, p.Total)
+		}
+		seen[p.Done] = true
+		if p.Err != nil {
+			failed++
+		}
+	}
+	if failed != 1 {
+		t.Errorf("Progress reported %d failures, want 1.", failed)
+	}
+}
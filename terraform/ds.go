@@ -0,0 +1,245 @@
+package terraform
+
+import (
+	"context"
+	"encoding/hex"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/a2n/pchome"
+)
+
+// 每個 zone 最多的 DS 記錄數。
+const maxDS = 5
+
+// ds 區塊。PChome 不保存 digest type，讀取時依 digest 長度推測。
+type DSRecord struct {
+	KeyTag uint16
+	Algorithm uint8
+	// 1 為 SHA-1、2 為 SHA-256、4 為 SHA-384。
+	DigestType uint8
+	Digest string
+}
+
+// pchome_zone_ds 資源的狀態和設定。
+type ZoneDS struct {
+	// 等於 Zone，由 provider 填入。
+	ID string
+	Zone string
+	Records []DSRecord
+}
+
+// 轉成 DNSSEC 記錄，digest 一律大寫。
+func (zd ZoneDS) records() []pchome.DNSSEC {
+	records := make([]pchome.DNSSEC, 0, len(zd.Records))
+	for _, r := range zd.Records {
+		records = append(records, pchome.DNSSEC{KeyTag: r.KeyTag, Algorithm: r.Algorithm, Digest: strings.ToUpper(r.Digest)})
+	}
+
+	return records
+}
+
+// 由 DNSSEC 記錄產生排序過的狀態。
+func dsState(zone string, records []pchome.DNSSEC) *ZoneDS {
+	state := &ZoneDS {
+		ID: zone,
+		Zone: zone,
+		Records: make([]DSRecord, 0, len(records)),
+	}
+	for _, r := range records {
+		state.Records = append(state.Records, DSRecord {
+			KeyTag: r.KeyTag,
+			Algorithm: r.Algorithm,
+			DigestType: uint8(pchome.DigestType(r.Digest)),
+			Digest: strings.ToUpper(r.Digest),
+		})
+	}
+	sort.Slice(state.Records, func(i, j int) bool {
+		a, b := state.Records[i], state.Records[j]
+		if a.KeyTag != b.KeyTag {
+			return a.KeyTag < b.KeyTag
+		}
+		if a.Algorithm != b.Algorithm {
+			return a.Algorithm < b.Algorithm
+		}
+		return a.Digest < b.Digest
+	})
+
+	return state
+}
+
+// 比較記錄時不分 digest 大小寫。
+func upper(records []pchome.DNSSEC) []pchome.DNSSEC {
+	out := make([]pchome.DNSSEC, 0, len(records))
+	for _, r := range records {
+		r.Digest = strings.ToUpper(r.Digest)
+		out = append(out, r)
+	}
+
+	return out
+}
+
+// pchome_zone_ds 資源，管理 zone 在上層的 DS 記錄。
+type DSResource struct {
+	p *Provider
+}
+
+// 取得 pchome_zone_ds 資源。
+func (p *Provider) DS() *DSResource {
+	return &DSResource{p: p}
+}
+
+// plan 階段檢查設定，不連線。
+func (r *DSResource) Validate(config ZoneDS) Diagnostics {
+	var diags Diagnostics
+	zone := zoneName(config.Zone)
+	if !pchome.ValidHost(zone) || !strings.Contains(zone, ".") {
+		diags.add("zone", "Bad zone name " + config.Zone + ".")
+	}
+	if len(config.Records) > maxDS {
+		diags.add("ds", "A zone can have at most 5 DS records.")
+	}
+
+	seen := make(map[pchome.DNSSEC]bool)
+	for i, r := range config.Records {
+		attr := "ds." + strconv.Itoa(i)
+		if _, err := hex.DecodeString(r.Digest); err != nil || len(r.Digest) == 0 {
+			diags.add(attr + ".digest", "Digest is not hex.")
+			continue
+		}
+		switch r.DigestType {
+		case 1, 2, 4:
+			if pchome.DigestType(r.Digest) != int(r.DigestType) {
+				diags.add(attr + ".digest", "Digest length " + strconv.Itoa(len(r.Digest)) + " does not match digest type " + strconv.Itoa(int(r.DigestType)) + ".")
+			}
+		default:
+			diags.add(attr + ".digest_type", "Unsupported digest type " + strconv.Itoa(int(r.DigestType)) + ", use 1, 2 or 4.")
+		}
+
+		key := pchome.DNSSEC{KeyTag: r.KeyTag, Algorithm: r.Algorithm, Digest: strings.ToUpper(r.Digest)}
+		if seen[key] {
+			diags.add(attr, "Duplicated DS record.")
+		}
+		seen[key] = true
+	}
+
+	return diags
+}
+
+// 比較狀態和設定，prior 為 nil 表示資源還不存在。
+func (r *DSResource) Plan(prior *ZoneDS, config ZoneDS) (Plan, Diagnostics) {
+	if diags := r.Validate(config); len(diags) > 0 {
+		return Plan{}, diags
+	}
+
+	after := pchome.Zone{DNSSEC: config.records()}
+	switch {
+	case prior == nil:
+		return Plan{Action: ActionCreate, Changes: pchome.DiffZone(pchome.Zone{}, after)}, nil
+	case zoneName(prior.Zone) != zoneName(config.Zone):
+		return Plan{Action: ActionReplace, Changes: pchome.DiffZone(pchome.Zone{}, after)}, nil
+	}
+
+	changes := pchome.DiffZone(pchome.Zone{DNSSEC: prior.records()}, after)
+	if len(changes) == 0 {
+		return Plan{Action: ActionNone}, nil
+	}
+	return Plan{Action: ActionUpdate, Changes: changes}, nil
+}
+
+// 以設定取代 zone 的 DS 記錄。
+func (r *DSResource) Create(ctx context.Context, config ZoneDS) (*ZoneDS, error) {
+	if err := r.Validate(config).Err(); err != nil {
+		return nil, err
+	}
+
+	return r.set(ctx, zoneName(config.Zone), config.records())
+}
+
+// 讀取網站上的 DS 記錄，zone 已經不在帳號時回傳 nil。
+func (r *DSResource) Read(ctx context.Context, state ZoneDS) (*ZoneDS, error) {
+	zone := zoneName(state.Zone)
+	if len(zone) == 0 {
+		zone = zoneName(state.ID)
+	}
+	s, err := r.p.service()
+	if err != nil {
+		return nil, err
+	}
+	ok, err := r.p.exists(ctx, s, zone)
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	records, err := s.NewDNSSECService().List(zone)
+	if err != nil {
+		return nil, err
+	}
+	return dsState(zone, records), nil
+}
+
+// 以設定取代 zone 的 DS 記錄，zone 不同時應該依 Plan 先刪除再建立。
+func (r *DSResource) Update(ctx context.Context, prior, config ZoneDS) (*ZoneDS, error) {
+	return r.Create(ctx, config)
+}
+
+// 移除 zone 的所有 DS 記錄。zone 已經不在帳號時視為成功。
+func (r *DSResource) Delete(ctx context.Context, state ZoneDS) error {
+	zone := zoneName(state.Zone)
+	s, err := r.p.service()
+	if err != nil {
+		return err
+	}
+	ok, err := r.p.exists(ctx, s, zone)
+	if err != nil || !ok {
+		return err
+	}
+
+	_, err = r.set(ctx, zone, []pchome.DNSSEC{})
+	return err
+}
+
+// 以 zone 名稱匯入。
+func (r *DSResource) Import(ctx context.Context, id string) (*ZoneDS, error) {
+	state, err := r.Read(ctx, ZoneDS{ID: zoneName(id)})
+	if err != nil {
+		return nil, err
+	}
+	if state == nil {
+		return nil, errNoZone(id)
+	}
+
+	return state, nil
+}
+
+// 提交和網站上不同的 DS 記錄，回傳提交後的狀態。
+func (r *DSResource) set(ctx context.Context, zone string, records []pchome.DNSSEC) (*ZoneDS, error) {
+	s, err := r.p.service()
+	if err != nil {
+		return nil, err
+	}
+	err = r.p.lock(func() error {
+		if err := r.p.ensure(ctx, s, zone); err != nil {
+			return err
+		}
+
+		ds := s.NewDNSSECService()
+		current, err := ds.List(zone)
+		if err != nil {
+			return err
+		}
+		if len(pchome.DiffZone(pchome.Zone{DNSSEC: upper(current)}, pchome.Zone{DNSSEC: records})) == 0 {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return ds.Set(zone, records)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return r.Read(ctx, ZoneDS{Zone: zone})
+}
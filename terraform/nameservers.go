@@ -0,0 +1,208 @@
+package terraform
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/a2n/pchome"
+)
+
+// 每個 zone 最多的 NS 記錄數。
+const maxNameservers = 5
+
+// nameserver 區塊。
+type Nameserver struct {
+	Host string
+	// glue IP，主機在 zone 之內時必填。兩者都有時以逗號分隔 IPv4 和 IPv6。
+	IP string
+}
+
+// pchome_zone_nameservers 資源的狀態和設定。
+type ZoneNameservers struct {
+	// 等於 Zone，由 provider 填入。
+	ID string
+	Zone string
+	Nameservers []Nameserver
+}
+
+// 轉成 NS 記錄。
+func (zn ZoneNameservers) record() pchome.NS {
+	record := make(pchome.NS)
+	for _, ns := range zn.Nameservers {
+		record[zoneName(ns.Host)] = ns.IP
+	}
+
+	return record
+}
+
+// 由 NS 記錄產生依主機名稱排序的狀態。
+func nameserversState(zone string, record pchome.NS) *ZoneNameservers {
+	state := &ZoneNameservers {
+		ID: zone,
+		Zone: zone,
+		Nameservers: make([]Nameserver, 0, len(record)),
+	}
+	for host, ip := range record {
+		state.Nameservers = append(state.Nameservers, Nameserver{Host: host, IP: ip})
+	}
+	sort.Slice(state.Nameservers, func(i, j int) bool { return state.Nameservers[i].Host < state.Nameservers[j].Host })
+
+	return state
+}
+
+// pchome_zone_nameservers 資源，管理 zone 委派的 name server 和 glue。
+type NameserversResource struct {
+	p *Provider
+}
+
+// 取得 pchome_zone_nameservers 資源。
+func (p *Provider) Nameservers() *NameserversResource {
+	return &NameserversResource{p: p}
+}
+
+// plan 階段檢查設定，不連線。
+func (r *NameserversResource) Validate(config ZoneNameservers) Diagnostics {
+	var diags Diagnostics
+	zone := zoneName(config.Zone)
+	if !pchome.ValidHost(zone) || !strings.Contains(zone, ".") {
+		diags.add("zone", "Bad zone name " + config.Zone + ".")
+	}
+
+	if len(config.Nameservers) == 0 {
+		diags.add("nameserver", "At least one name server is required.")
+	}
+	if len(config.Nameservers) > maxNameservers {
+		diags.add("nameserver", "A zone can have at most 5 name servers.")
+	}
+
+	seen := make(map[string]bool)
+	for i, ns := range config.Nameservers {
+		attr := "nameserver." + strconv.Itoa(i)
+		host := zoneName(ns.Host)
+		if !pchome.ValidHost(host) {
+			diags.add(attr + ".host", "Bad host name " + ns.Host + ".")
+			continue
+		}
+		if seen[host] {
+			diags.add(attr + ".host", "Duplicated host name " + host + ".")
+		}
+		seen[host] = true
+
+		if !pchome.ValidGlue(ns.IP) {
+			diags.add(attr + ".ip", "Bad ip " + ns.IP + ".")
+		}
+		if len(ns.IP) == 0 && (host == zone || strings.HasSuffix(host, "." + zone)) {
+			diags.add(attr + ".ip", "Host " + host + " is inside the zone and needs a glue ip.")
+		}
+	}
+
+	return diags
+}
+
+// 比較狀態和設定，prior 為 nil 表示資源還不存在。
+func (r *NameserversResource) Plan(prior *ZoneNameservers, config ZoneNameservers) (Plan, Diagnostics) {
+	if diags := r.Validate(config); len(diags) > 0 {
+		return Plan{}, diags
+	}
+
+	after := pchome.Zone{NS: config.record()}
+	switch {
+	case prior == nil:
+		return Plan{Action: ActionCreate, Changes: pchome.DiffZone(pchome.Zone{}, after)}, nil
+	case zoneName(prior.Zone) != zoneName(config.Zone):
+		return Plan{Action: ActionReplace, Changes: pchome.DiffZone(pchome.Zone{}, after)}, nil
+	}
+
+	changes := pchome.DiffZone(pchome.Zone{NS: prior.record()}, after)
+	if len(changes) == 0 {
+		return Plan{Action: ActionNone}, nil
+	}
+	return Plan{Action: ActionUpdate, Changes: changes}, nil
+}
+
+// 以設定取代 zone 的 NS 記錄。
+func (r *NameserversResource) Create(ctx context.Context, config ZoneNameservers) (*ZoneNameservers, error) {
+	if err := r.Validate(config).Err(); err != nil {
+		return nil, err
+	}
+
+	return r.set(ctx, zoneName(config.Zone), config.record())
+}
+
+// 讀取網站上的 NS 記錄，zone 已經不在帳號時回傳 nil。
+func (r *NameserversResource) Read(ctx context.Context, state ZoneNameservers) (*ZoneNameservers, error) {
+	zone := zoneName(state.Zone)
+	if len(zone) == 0 {
+		zone = zoneName(state.ID)
+	}
+	s, err := r.p.service()
+	if err != nil {
+		return nil, err
+	}
+	ok, err := r.p.exists(ctx, s, zone)
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	record, err := s.NewNSService().List(zone)
+	if err != nil {
+		return nil, err
+	}
+	return nameserversState(zone, record), nil
+}
+
+// 以設定取代 zone 的 NS 記錄，zone 不同時應該依 Plan 先刪除再建立。
+func (r *NameserversResource) Update(ctx context.Context, prior, config ZoneNameservers) (*ZoneNameservers, error) {
+	return r.Create(ctx, config)
+}
+
+// 停止管理 zone 的委派。zone 必須委派給某些 name server，所以不會改變網站上的記錄。
+func (r *NameserversResource) Delete(ctx context.Context, state ZoneNameservers) error {
+	return ctx.Err()
+}
+
+// 以 zone 名稱匯入。
+func (r *NameserversResource) Import(ctx context.Context, id string) (*ZoneNameservers, error) {
+	state, err := r.Read(ctx, ZoneNameservers{ID: zoneName(id)})
+	if err != nil {
+		return nil, err
+	}
+	if state == nil {
+		return nil, errNoZone(id)
+	}
+
+	return state, nil
+}
+
+// 提交和網站上不同的 NS 記錄，回傳提交後的狀態。
+func (r *NameserversResource) set(ctx context.Context, zone string, record pchome.NS) (*ZoneNameservers, error) {
+	s, err := r.p.service()
+	if err != nil {
+		return nil, err
+	}
+	err = r.p.lock(func() error {
+		if err := r.p.ensure(ctx, s, zone); err != nil {
+			return err
+		}
+
+		ns := s.NewNSService()
+		current, err := ns.List(zone)
+		if err != nil {
+			return err
+		}
+		if len(pchome.DiffZone(pchome.Zone{NS: current}, pchome.Zone{NS: record})) == 0 {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return ns.Set(zone, record)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return r.Read(ctx, ZoneNameservers{Zone: zone})
+}
@@ -0,0 +1,153 @@
+// Package terraform 以 Terraform 資源的模型管理 PChome 的委派，提供 pchome_zone_nameservers 和 pchome_zone_ds。
+//
+// 每個資源以 zone 名稱為 ID，可以用 zone 名稱匯入。Validate 在 plan 階段檢查設定，
+// Plan 比較狀態和設定決定動作，Create、Read、Update 和 Delete 透過 NSService 和 DNSSECService 提交，
+// 所以異動同樣會留下快照、稽核紀錄和通知。Terraform plugin 只需要把 schema 的屬性轉成這裡的結構。
+package terraform
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/a2n/pchome"
+)
+
+// 資源類型名稱。
+const (
+	NameserversType = "pchome_zone_nameservers"
+	DSType = "pchome_zone_ds"
+)
+
+// 計畫的動作。
+const (
+	ActionNone = "no-op"
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	// zone 改變時需要先刪除再建立。
+	ActionReplace = "replace"
+)
+
+// 設定的問題，Attribute 為屬性路徑，例如 nameserver.0.host。
+type Diagnostic struct {
+	Attribute string
+	Summary string
+}
+
+// plan 階段發現的所有問題。
+type Diagnostics []Diagnostic
+
+func (d Diagnostics) Error() string {
+	msgs := make([]string, 0, len(d))
+	for _, diag := range d {
+		if len(diag.Attribute) == 0 {
+			msgs = append(msgs, diag.Summary)
+		} else {
+			msgs = append(msgs, diag.Attribute + ": " + diag.Summary)
+		}
+	}
+
+	return strings.Join(msgs, "; ")
+}
+
+// 沒有問題時回傳 nil。
+func (d Diagnostics) Err() error {
+	if len(d) == 0 {
+		return nil
+	}
+
+	return d
+}
+
+func (d *Diagnostics) add(attribute, summary string) {
+	*d = append(*d, Diagnostic{Attribute: attribute, Summary: summary})
+}
+
+// 計畫的結果，Changes 為要提交的記錄異動。
+type Plan struct {
+	Action string
+	Changes []pchome.Change
+}
+
+// Provider 設定。
+type Provider struct {
+	// 組態服務，資源的 zone 不在組態時會先同步。
+	ConfigService *pchome.ConfigService
+	// 取得已登入的服務，nil 時使用 ConfigService.NewService。
+	Service func() (*pchome.Service, error)
+	// 持有組態鎖執行寫入，同步 zone 和提交記錄都會寫入組態。nil 時使用 pchome.WithLock。
+	Lock func(fn func() error) error
+}
+
+// 取得使用 cs 的 provider。
+func NewProvider(cs *pchome.ConfigService) *Provider {
+	return &Provider {
+		ConfigService: cs,
+	}
+}
+
+// 取得所有資源類型名稱。
+func (p *Provider) ResourceTypes() []string {
+	return []string{NameserversType, DSType}
+}
+
+func (p *Provider) service() (*pchome.Service, error) {
+	if p.Service != nil {
+		return p.Service()
+	}
+
+	return p.ConfigService.NewService()
+}
+
+func (p *Provider) lock(fn func() error) error {
+	if p.Lock != nil {
+		return p.Lock(fn)
+	}
+
+	return pchome.WithLock(fn)
+}
+
+// 確認 zone 屬於帳號，回傳 false 表示 zone 已經不存在。
+func (p *Provider) exists(ctx context.Context, s *pchome.Service, zone string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	zones, err := s.NewZoneService().List().Do()
+	if err != nil {
+		return false, err
+	}
+
+	_, ok := zones[zone]
+	return ok, nil
+}
+
+// 確認 zone 屬於帳號並已同步到組態，NSService 和 DNSSECService 需要組態裡的 zone。
+func (p *Provider) ensure(ctx context.Context, s *pchome.Service, zone string) error {
+	ok, err := p.exists(ctx, s, zone)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("No zone " + zone + " in the PChome account.")
+	}
+
+	config, err := p.ConfigService.Read()
+	if err != nil {
+		return err
+	}
+	if _, ok := config.Zones[zone]; ok {
+		return nil
+	}
+	_, err = p.ConfigService.UpdateZonesByNames(s, []string{zone})
+	return err
+}
+
+// 正規化 zone 名稱。
+func zoneName(zone string) string {
+	return strings.ToLower(strings.TrimSuffix(strings.TrimSpace(zone), "."))
+}
+
+// 匯入的 zone 不在帳號。
+func errNoZone(id string) error {
+	return errors.New("Cannot import " + id + ", no such zone in the PChome account.")
+}
@@ -0,0 +1,146 @@
+package terraform
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/a2n/pchome"
+	"github.com/a2n/pchome/pchometest"
+)
+
+// 在暫存目錄建立空的組態，回傳連到模擬網站的 provider。
+func newTestProvider(t *testing.T) (*Provider, *pchometest.Server) {
+	srv := pchometest.NewServer()
+	t.Cleanup(srv.Close)
+	srv.SetZone("example.com", pchome.Zone{NS: pchome.NS{"ns1.example.net": "", "ns2.example.net": ""}})
+
+	dir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err.Error())
+	}
+	t.Cleanup(func() { os.Chdir(dir) })
+
+	cs := pchome.NewConfigService()
+	if err := cs.Save(&pchome.Config{Zones: map[string]pchome.Zone{}}); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	p := NewProvider(cs)
+	p.Service = func() (*pchome.Service, error) { return srv.Service(), nil }
+	return p, srv
+}
+
+func TestNameserversValidate(t *testing.T) {
+	r := NewProvider(nil).Nameservers()
+	config := ZoneNameservers {
+		Zone: "example.com",
+		Nameservers: []Nameserver {
+			{Host: "ns1.example.com"},
+			{Host: "bad_host"},
+			{Host: "ns2.example.net", IP: "300.1.1.1"},
+			{Host: "NS2.example.net."},
+		},
+	}
+
+	attrs := make([]string, 0)
+	for _, diag := range r.Validate(config) {
+		attrs = append(attrs, diag.Attribute)
+	}
+	want := "nameserver.0.ip nameserver.1.host nameserver.2.ip nameserver.3.host"
+	if strings.Join(attrs, " ") != want {
+		t.Errorf("Diagnostics on %v, want %s.", attrs, want)
+	}
+	if _, diags := r.Plan(nil, ZoneNameservers{Zone: "example.com"}); len(diags) == 0 {
+		t.Error("Planning without name servers succeeds.")
+	}
+}
+
+func TestNameservers(t *testing.T) {
+	p, srv := newTestProvider(t)
+	r := p.Nameservers()
+	ctx := context.Background()
+
+	state, err := r.Import(ctx, "Example.COM.")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if state.ID != "example.com" || len(state.Nameservers) != 2 || state.Nameservers[0].Host != "ns1.example.net" {
+		t.Fatalf("Imported %+v.", state)
+	}
+
+	config := ZoneNameservers {
+		Zone: "example.com",
+		Nameservers: []Nameserver{{Host: "ns1.example.com", IP: "192.0.2.1"}, {Host: "ns2.example.net"}},
+	}
+	plan, diags := r.Plan(state, config)
+	if len(diags) > 0 || plan.Action != ActionUpdate || len(plan.Changes) != 2 {
+		t.Fatalf("Plan %+v with diagnostics %v.", plan, diags)
+	}
+	if state, err = r.Update(ctx, *state, config); err != nil {
+		t.Fatal(err.Error())
+	}
+	if zone, _ := srv.Zone("example.com"); zone.NS["ns1.example.com"] != "192.0.2.1" || len(zone.NS) != 2 {
+		t.Errorf("Site has NS %v.", zone.NS)
+	}
+	if plan, _ := r.Plan(state, config); plan.Action != ActionNone {
+		t.Errorf("Plan after applying is %s.", plan.Action)
+	}
+
+	posts := srv.Posts()
+	if _, err := r.Create(ctx, config); err != nil || srv.Posts() != posts {
+		t.Errorf("Creating unchanged records has error %v and posts %d times.", err, srv.Posts() - posts)
+	}
+	if plan, _ := r.Plan(state, ZoneNameservers{Zone: "example.org", Nameservers: config.Nameservers}); plan.Action != ActionReplace {
+		t.Errorf("Plan for another zone is %s.", plan.Action)
+	}
+
+	srv.RemoveZone("example.com")
+	if state, err := r.Read(ctx, *state); err != nil || state != nil {
+		t.Errorf("Reading a removed zone returns %+v, %v.", state, err)
+	}
+	if _, err := r.Create(ctx, config); err == nil {
+		t.Error("Creating on a removed zone succeeds.")
+	}
+}
+
+func TestDS(t *testing.T) {
+	p, srv := newTestProvider(t)
+	r := p.DS()
+	ctx := context.Background()
+	digest := "4355a46b19d348dc2f57c046f8ef63d4538ebb936000f3c9ee954a27460dd865"
+
+	if diags := r.Validate(ZoneDS{Zone: "example.com", Records: []DSRecord{{KeyTag: 1, Algorithm: 13, DigestType: 1, Digest: digest}}}); len(diags) != 1 {
+		t.Errorf("Mismatched digest type has diagnostics %v.", diags)
+	}
+
+	config := ZoneDS{Zone: "example.com", Records: []DSRecord{{KeyTag: 2371, Algorithm: 13, DigestType: 2, Digest: digest}}}
+	plan, diags := r.Plan(nil, config)
+	if len(diags) > 0 || plan.Action != ActionCreate || len(plan.Changes) != 1 {
+		t.Fatalf("Plan %+v with diagnostics %v.", plan, diags)
+	}
+	state, err := r.Create(ctx, config)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(state.Records) != 1 || state.Records[0].DigestType != 2 || state.Records[0].Digest != strings.ToUpper(digest) {
+		t.Errorf("State %+v.", state)
+	}
+	if zone, _ := srv.Zone("example.com"); len(zone.DNSSEC) != 1 || zone.DNSSEC[0].KeyTag != 2371 {
+		t.Errorf("Site has DS %v.", zone.DNSSEC)
+	}
+	if plan, _ := r.Plan(state, config); plan.Action != ActionNone {
+		t.Errorf("Plan after applying is %s.", plan.Action)
+	}
+
+	if err := r.Delete(ctx, *state); err != nil {
+		t.Fatal(err.Error())
+	}
+	if zone, _ := srv.Zone("example.com"); len(zone.DNSSEC) != 0 {
+		t.Errorf("Site has DS %v after deleting.", zone.DNSSEC)
+	}
+}
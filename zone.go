@@ -2,16 +2,20 @@ package pchome
 
 import (
 	"net/http"
-	"io/ioutil"
+	"net/url"
 	"regexp"
-
-	"github.com/a2n/alu"
+	"path"
+	"sort"
+	"strings"
+	"errors"
 )
 
 // Zone 結構。
 type Zone struct {
 	NS NS
 	DNSSEC []DNSSEC
+	// 最後同步時間。
+	UpdatedAt int64
 }
 
 // Zone 服務結構。
@@ -35,43 +39,185 @@ type ZoneListCall struct {
 }
 
 // 執行 zone 列舉調用。
-func (zlc *ZoneListCall) Do() map[string]Zone {
+func (zlc *ZoneListCall) Do() (map[string]Zone, error) {
 	urlstr := ENDPOINT + "/index.htm"
 	req, err := http.NewRequest("GET", urlstr, nil)
 	if err != nil {
-		logger.Printf("%s creates request failed, %s.", alu.Caller(), err.Error())
+		zlc.Service.log().Error("creates request failed", LogError, err)
+		return nil, errors.New("Cannot create a http request.")
 	}
 	zlc.Service.SetCookie(req)
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := zlc.Service.Do(req)
 	if err != nil {
-		logger.Printf("%s requesting failed, %s.", alu.Caller(), err.Error())
+		zlc.Service.log().Error("requesting failed", LogError, err)
+		return nil, errors.New("Having http requesting failed.")
+	}
+	if err := checkStatus(zlc.Service.log(), resp); err != nil {
+		return nil, err
 	}
 
-	b, err := ioutil.ReadAll(resp.Body)
+	b, err := zlc.Service.readBody(resp)
+	resp.Body.Close()
 	if err != nil {
-		logger.Printf("%s requesting failed, %s.", alu.Caller(), err.Error())
+		return nil, err
 	}
-	resp.Body.Close()
-	return zlc.Parse(b)
+
+	zones, err := zlc.Parse(b)
+	if err != nil {
+		return nil, zlc.Service.parseFailed(err)
+	}
+
+	return zones, nil
 }
 
-// 解析 zone 列舉調用結果。
-func (zlc *ZoneListCall) Parse(raw []byte) map[string]Zone {
+// 解析 zone 列舉調用結果，zone 名稱取自「進入」連結的 dn 參數。
+func (zlc *ZoneListCall) Parse(raw []byte) (map[string]Zone, error) {
 	zones := make(map[string]Zone)
 	if len(raw) == 0 {
-		logger.Printf("%s has empty raw.", alu.Caller())
-		return zones
+		zlc.Service.log().Error("has empty raw")
+		return zones, nil
+	}
+
+	p := parsePage(raw)
+	if p.isLogin() {
+		zlc.Service.log().Error("gets the login page")
+		return nil, &ParseError{Page: "zone list", Msg: "is the login page"}
+	}
+
+	for _, link := range p.Links {
+		if !strings.Contains(link.Text, "進入") {
+			continue
+		}
+
+		u, err := url.Parse(link.Href)
+		if err != nil {
+			zlc.Service.log().Warn("parse link failed", "href", link.Href, LogError, err)
+			return nil, &ParseError{Page: "zone list", Field: "href", Msg: "is not a url"}
+		}
+		dn := u.Query().Get("dn")
+		if len(dn) == 0 {
+			zlc.Service.log().Warn("link without dn", "href", link.Href)
+			return nil, &ParseError{Page: "zone list", Field: "dn", Msg: "is missing"}
+		}
+		zones[strings.ToLower(dn)] = Zone{}
 	}
 
-	re := regexp.MustCompile(`\?dn=(.*)">進入`)
-	if !re.Match(raw) {
-	    return zones
+	return zones, nil
+}
+
+// 取得選取 zone 調用結構。
+func (zs *ZoneService) Select() *ZoneSelectCall {
+	return &ZoneSelectCall {
+		Service: zs.Service,
 	}
-	ms := re.FindAllStringSubmatch(string(raw), -1)
-	for _, v := range ms {
-		zones[v[1]] = Zone{}
+}
+
+// zone 選取調用結構，符合任一條件的 zone 都會被選取。
+type ZoneSelectCall struct {
+	Service *Service
+	zones map[string]Zone
+	names []string
+	exprs []string
+	globs []string
+	tlds []string
+}
+
+// 指定要選取的 zone 集合，未指定時使用本地組態的 zone。
+func (zsc *ZoneSelectCall) Zones(zones map[string]Zone) *ZoneSelectCall {
+	zsc.zones = zones
+	return zsc
+}
+
+// 選取名稱完全相同的 zone。
+func (zsc *ZoneSelectCall) Name(names ...string) *ZoneSelectCall {
+	zsc.names = append(zsc.names, names...)
+	return zsc
+}
+
+// 選取整個名稱符合 regex 的 zone，例如 .*tw。
+func (zsc *ZoneSelectCall) Regexp(expr string) *ZoneSelectCall {
+	zsc.exprs = append(zsc.exprs, expr)
+	return zsc
+}
+
+// 選取名稱符合 glob 的 zone，例如 *.com.tw。
+func (zsc *ZoneSelectCall) Glob(pattern string) *ZoneSelectCall {
+	zsc.globs = append(zsc.globs, pattern)
+	return zsc
+}
+
+// 選取 TLD 相同的 zone，例如 tw 或 com.tw。
+func (zsc *ZoneSelectCall) TLD(tld string) *ZoneSelectCall {
+	zsc.tlds = append(zsc.tlds, strings.Trim(strings.ToLower(tld), "."))
+	return zsc
+}
+
+// 執行 zone 選取調用，回傳排序後的 zone 名稱。
+func (zsc *ZoneSelectCall) Do() ([]string, error) {
+	zones := zsc.zones
+	if zones == nil {
+		config, err := zsc.Service.configService().Read()
+		if err != nil {
+			return nil, err
+		}
+		zones = config.Zones
+	}
+
+	res := make([]*regexp.Regexp, 0, len(zsc.exprs))
+	for _, expr := range zsc.exprs {
+		re, err := regexp.Compile("^(?:" + expr + ")$")
+		if err != nil {
+			zsc.Service.log().Error("compile regexp failed", "expr", expr, LogError, err)
+			return nil, errors.New("Invalid zone regex.")
+		}
+		res = append(res, re)
+	}
+
+	for _, pattern := range zsc.globs {
+		if _, err := path.Match(pattern, ""); err != nil {
+			zsc.Service.log().Error("bad glob pattern", "pattern", pattern, LogError, err)
+			return nil, errors.New("Invalid zone glob pattern.")
+		}
+	}
+
+	names := make([]string, 0)
+	for name := range zones {
+		if zsc.match(name, res) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// 檢查 zone 名稱是否符合任一條件。
+func (zsc *ZoneSelectCall) match(name string, res []*regexp.Regexp) bool {
+	lower := strings.ToLower(name)
+	for _, n := range zsc.names {
+		if strings.ToLower(n) == lower {
+			return true
+		}
+	}
+
+	for _, re := range res {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+
+	for _, pattern := range zsc.globs {
+		if ok, _ := path.Match(pattern, lower); ok {
+			return true
+		}
+	}
+
+	for _, tld := range zsc.tlds {
+		if strings.HasSuffix(lower, "." + tld) {
+			return true
+		}
 	}
 
-	return zones
+	return false
 }
@@ -0,0 +1,52 @@
+package pchome
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestZoneSelect(t *testing.T) {
+	zones := map[string]Zone {
+		"example.com": {},
+		"myexample.com.tw": {},
+		"example.com.tw": {},
+		"shop.tw": {},
+		"Example.NET": {},
+	}
+
+	tests := []struct {
+		name string
+		call func(zsc *ZoneSelectCall) *ZoneSelectCall
+		want string
+	}{
+		{"name", func(zsc *ZoneSelectCall) *ZoneSelectCall { return zsc.Name("EXAMPLE.com", "none.com") }, "example.com"},
+		{"name case", func(zsc *ZoneSelectCall) *ZoneSelectCall { return zsc.Name("example.net") }, "Example.NET"},
+		{"regexp anchored", func(zsc *ZoneSelectCall) *ZoneSelectCall { return zsc.Regexp(`example\.com`) }, "example.com"},
+		{"regexp suffix", func(zsc *ZoneSelectCall) *ZoneSelectCall { return zsc.Regexp(`.*example\.com\.tw`) }, "example.com.tw myexample.com.tw"},
+		{"glob", func(zsc *ZoneSelectCall) *ZoneSelectCall { return zsc.Glob("*.com.tw") }, "example.com.tw myexample.com.tw"},
+		{"glob star crosses dots", func(zsc *ZoneSelectCall) *ZoneSelectCall { return zsc.Glob("example.*") }, "Example.NET example.com example.com.tw"},
+		{"tld", func(zsc *ZoneSelectCall) *ZoneSelectCall { return zsc.TLD(".TW") }, "example.com.tw myexample.com.tw shop.tw"},
+		{"tld second level", func(zsc *ZoneSelectCall) *ZoneSelectCall { return zsc.TLD("com.tw") }, "example.com.tw myexample.com.tw"},
+		{"union", func(zsc *ZoneSelectCall) *ZoneSelectCall { return zsc.Name("shop.tw").TLD("com") }, "example.com shop.tw"},
+		{"none", func(zsc *ZoneSelectCall) *ZoneSelectCall { return zsc }, ""},
+	}
+
+	s := NewService("key")
+	for _, test := range tests {
+		names, err := test.call(s.NewZoneService().Select().Zones(zones)).Do()
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err.Error())
+			continue
+		}
+		if got := strings.Join(names, " "); got != test.want {
+			t.Errorf("%s: got %q, want %q.", test.name, got, test.want)
+		}
+	}
+
+	if _, err := s.NewZoneService().Select().Zones(zones).Regexp("(").Do(); err == nil {
+		t.Error("Selecting with a bad regex succeeds.")
+	}
+	if _, err := s.NewZoneService().Select().Zones(zones).Glob("[").Do(); err == nil {
+		t.Error("Selecting with a bad glob succeeds.")
+	}
+}